@@ -2,9 +2,11 @@ package main
 
 import (
 	"context"
+	"expvar"
 	"flag"
 	"log/slog"
 	"net"
+	"net/http"
 	"net/mail"
 	"net/smtp"
 	"os"
@@ -78,6 +80,14 @@ func main() {
 		return isp.GetWebDomains(cfg.MgrCtlPath)
 	}
 
+	if cfg.MetricsAddr != "" {
+		go func() {
+			if err := http.ListenAndServe(cfg.MetricsAddr, expvar.Handler()); err != nil {
+				slog.Error("failed to serve metrics", "err", err)
+			}
+		}()
+	}
+
 	chk := checker.NewChecker(cfg, notify.NewNotifier(cfg, sender), webDomainsFunc)
 
 	if err := chk.Start(); err != nil {
@@ -0,0 +1,239 @@
+package checker
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+	"github.com/kias-hack/isp-site-checker/internal/notify"
+)
+
+// auditSchedule keeps the time of the next audit of every site.
+type auditSchedule struct {
+	interval time.Duration
+	next     map[string]time.Time
+}
+
+// newAuditSchedule returns nil when the audit is disabled, a nil schedule has no due sites.
+func newAuditSchedule(enabled bool, interval time.Duration) *auditSchedule {
+	if !enabled {
+		return nil
+	}
+
+	return &auditSchedule{interval: interval, next: make(map[string]time.Time)}
+}
+
+// due reports whether the site is audited at now, a site not known to the schedule yet is not due.
+func (a *auditSchedule) due(site string, now time.Time) bool {
+	if a == nil {
+		return false
+	}
+
+	next, ok := a.next[site]
+
+	return ok && !next.After(now)
+}
+
+// done moves the site to the next audit after a completed one.
+func (a *auditSchedule) done(site string, now time.Time) {
+	if a == nil {
+		return
+	}
+
+	a.next[site] = now.Add(a.interval)
+}
+
+// refresh drops the sites that are gone from the domain list, the first audits of the new sites are spread
+// over the interval by the hash of the site name.
+func (a *auditSchedule) refresh(current map[string]bool, now time.Time) {
+	if a == nil {
+		return
+	}
+
+	for site := range a.next {
+		if !current[site] {
+			delete(a.next, site)
+		}
+	}
+
+	for site := range current {
+		if _, ok := a.next[site]; !ok {
+			a.next[site] = now.Add(siteOffset(site, a.interval))
+		}
+	}
+}
+
+// audits adds the security audits to the tasks at a much lower cadence than the checks. The scheduler marks
+// the due audits, the result handler moves the site to the next audit once the audit is completed, so an audit
+// dropped or failed on the way is repeated with the next check.
+type audits struct {
+	mu        sync.Mutex
+	sensitive *auditSchedule
+	listings  *auditSchedule
+	noindex   *auditSchedule
+	files     []config.SensitiveFile
+	paths     []string
+	staging   func(site string) bool
+}
+
+func newAudits(cfg *config.Config) *audits {
+	return &audits{
+		sensitive: newAuditSchedule(cfg.SensitiveFiles.Enabled && len(cfg.SensitiveFiles.Signatures) > 0, cfg.SensitiveFiles.Interval),
+		listings:  newAuditSchedule(cfg.DirectoryListing.Enabled && len(cfg.DirectoryListing.Paths) > 0, cfg.DirectoryListing.Interval),
+		noindex:   newAuditSchedule(cfg.NoIndex.Enabled, cfg.NoIndex.Interval),
+		files:     cfg.SensitiveFiles.Signatures,
+		paths:     cfg.DirectoryListing.Paths,
+		staging:   cfg.SiteStaging,
+	}
+}
+
+// mark adds the due audits to the tasks.
+func (a *audits) mark(tasks []*Task, now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, task := range tasks {
+		if a.sensitive.due(task.Site, now) {
+			task.SensitiveFiles = a.files
+		}
+
+		if a.listings.due(task.Site, now) {
+			task.ListingPaths = a.paths
+		}
+
+		if a.staging(task.Site) && a.noindex.due(task.Site, now) {
+			task.NoIndex = true
+		}
+
+		if task.SensitiveFiles != nil || task.ListingPaths != nil || task.NoIndex {
+			task.Audits = a
+		}
+	}
+}
+
+// finish moves the site of the task to the next audit of every audit completed by the task.
+func (a *audits) finish(task *Task, now time.Time) {
+	if a == nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := sensitiveFound(task); ok && len(task.SensitiveFiles) > 0 {
+		a.sensitive.done(task.Site, now)
+	}
+
+	if _, ok := listingsFound(task); ok && len(task.ListingPaths) > 0 {
+		a.listings.done(task.Site, now)
+	}
+
+	if _, ok := noindexFound(task); ok && task.NoIndex {
+		a.noindex.done(task.Site, now)
+	}
+}
+
+func (a *audits) refresh(tasks []*Task, now time.Time) {
+	current := make(map[string]bool, len(tasks))
+	for _, task := range tasks {
+		current[task.Site] = true
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.sensitive.refresh(current, now)
+	a.listings.refresh(current, now)
+	a.noindex.refresh(current, now)
+}
+
+// ownerFindings keeps the findings of every site by owner.
+type ownerFindings map[string]map[string][]string
+
+// update records the findings of the site and reports whether the findings of the owner changed.
+func (f ownerFindings) update(owner string, site string, found []string) bool {
+	sites := f[owner]
+	if slices.Equal(sites[site], found) {
+		return false
+	}
+
+	if len(found) == 0 {
+		delete(sites, site)
+
+		if len(sites) == 0 {
+			delete(f, owner)
+		}
+
+		return true
+	}
+
+	if sites == nil {
+		sites = make(map[string][]string)
+		f[owner] = sites
+	}
+
+	sites[site] = found
+
+	return true
+}
+
+// report lists the findings of all sites of the owner.
+func (f ownerFindings) report(owner string) string {
+	var lines []string
+
+	for _, found := range f[owner] {
+		lines = append(lines, found...)
+	}
+
+	slices.Sort(lines)
+
+	return strings.Join(lines, "\n")
+}
+
+// findingReporter sends the findings of one kind per owner, it's used by the result handler goroutine only.
+type findingReporter struct {
+	kind     notify.FindingKind
+	title    string
+	resolved string
+	findings ownerFindings
+}
+
+// newFindingReporter takes the title of the finding message and the format of the message about the resolved
+// findings of the owner.
+func newFindingReporter(kind notify.FindingKind, title string, resolved string) *findingReporter {
+	return &findingReporter{
+		kind:     kind,
+		title:    title,
+		resolved: resolved,
+		findings: make(ownerFindings),
+	}
+}
+
+// report records the findings of the task site and sends the findings of its owner, every audit keeps the finding
+// alive in the notifier.
+func (r *findingReporter) report(notifier notify.Notifier, task *Task, found []string) {
+	changed := r.findings.update(task.Owner, task.Site, found)
+	key := fmt.Sprintf("%s:%s", r.kind, task.Owner)
+
+	if report := r.findings.report(task.Owner); report != "" {
+		notifier.Finding(r.kind, key, buildFindingMessage(r.title, task.Owner, report))
+		return
+	}
+
+	if changed {
+		notifier.Success(key, fmt.Sprintf(r.resolved, task.Owner))
+	}
+}
+
+func buildFindingMessage(title string, owner string, report string) string {
+	msg := strings.Builder{}
+
+	msg.WriteString(title + "\n")
+	msg.WriteString(fmt.Sprintf("Владелец: %s\n", owner))
+	msg.WriteString(report)
+
+	return msg.String()
+}
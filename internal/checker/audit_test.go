@@ -0,0 +1,88 @@
+package checker
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAudits(t *testing.T) {
+	cfg := &config.Config{
+		SensitiveFiles:   config.SensitiveFiles{Enabled: true, Interval: time.Hour},
+		DirectoryListing: config.DirectoryListing{Enabled: true, Interval: 2 * time.Hour, Paths: []string{"/", "/upload/"}},
+		NoIndex:          config.NoIndex{Enabled: true, Interval: time.Hour},
+		Sites:            []config.SiteConfig{{Name: "*.dev.ru", Staging: true}},
+	}
+	cfg.SensitiveFiles.Signatures = []config.SensitiveFile{{Path: "/.env", Content: "=", Signature: regexp.MustCompile("=")}}
+
+	a := newAudits(cfg)
+	now := time.Now()
+
+	tasks := []*Task{{Site: "a.dev.ru"}, {Site: "b.ru"}, {Site: "shop.ru"}}
+	a.refresh(tasks, now)
+
+	for _, task := range tasks {
+		assert.Equal(t, now.Add(siteOffset(task.Site, time.Hour)), a.sensitive.next[task.Site], "first audits are spread over the interval")
+	}
+
+	a.mark(tasks, now.Add(2*time.Hour))
+
+	for _, task := range tasks {
+		assert.Same(t, a, task.Audits)
+		assert.Equal(t, cfg.SensitiveFiles.Signatures, task.SensitiveFiles)
+		assert.Equal(t, cfg.DirectoryListing.Paths, task.ListingPaths)
+	}
+
+	assert.True(t, tasks[0].NoIndex)
+	assert.False(t, tasks[1].NoIndex, "sites are production unless marked as staging")
+	assert.False(t, tasks[2].NoIndex)
+
+	// only the sensitive files audit is completed, the failed noindex audit and the dropped listing audit are repeated
+	tasks[0].Result.SensitiveAudited = true
+	tasks[0].Result.NoIndex = &NoIndexResult{Err: errors.New("connection reset")}
+	tasks[0].Audits.finish(tasks[0], now.Add(2*time.Hour))
+
+	tasks = []*Task{{Site: "a.dev.ru"}, {Site: "b.ru"}}
+	a.mark(tasks, now.Add(2*time.Hour+time.Minute))
+	assert.Empty(t, tasks[0].SensitiveFiles, "the audit waits for the interval")
+	assert.Equal(t, cfg.DirectoryListing.Paths, tasks[0].ListingPaths)
+	assert.True(t, tasks[0].NoIndex)
+	assert.NotEmpty(t, tasks[1].SensitiveFiles, "the audit is due until it's completed")
+
+	a.mark(tasks, now.Add(3*time.Hour))
+	assert.NotEmpty(t, tasks[0].SensitiveFiles)
+
+	a.refresh([]*Task{{Site: "a.dev.ru"}}, now)
+	assert.NotContains(t, a.sensitive.next, "b.ru")
+	assert.NotContains(t, a.listings.next, "b.ru")
+	assert.NotContains(t, a.noindex.next, "b.ru")
+
+	(*audits)(nil).finish(&Task{Site: "a.dev.ru"}, now)
+
+	disabled := newAudits(&config.Config{})
+	tasks = []*Task{{Site: "a.ru"}}
+	disabled.refresh(tasks, now)
+	disabled.mark(tasks, now)
+	assert.Empty(t, tasks[0].SensitiveFiles)
+	assert.Empty(t, tasks[0].ListingPaths)
+	assert.False(t, tasks[0].NoIndex)
+}
+
+func TestOwnerFindings(t *testing.T) {
+	findings := make(ownerFindings)
+
+	assert.True(t, findings.update("client", "b.ru", []string{"http://b.ru/.git/HEAD - 200"}))
+	assert.True(t, findings.update("client", "a.ru", []string{"http://a.ru/.env - 200"}))
+	assert.False(t, findings.update("client", "a.ru", []string{"http://a.ru/.env - 200"}))
+	assert.False(t, findings.update("other", "c.ru", nil))
+	assert.Equal(t, "http://a.ru/.env - 200\nhttp://b.ru/.git/HEAD - 200", findings.report("client"))
+
+	assert.True(t, findings.update("client", "a.ru", nil))
+	assert.True(t, findings.update("client", "b.ru", nil))
+	assert.Empty(t, findings.report("client"))
+	assert.Empty(t, findings)
+}
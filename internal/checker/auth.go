@@ -0,0 +1,213 @@
+package checker
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+)
+
+var errNoAuthChallenge = errors.New("no Basic or Digest challenge in WWW-Authenticate")
+
+type authChallenge struct {
+	Scheme string
+	Realm  string
+	Params map[string]string
+}
+
+type AuthResult struct {
+	StatusCode int
+	Err        error
+}
+
+func (r *AuthResult) Broken() bool {
+	return r.Err != nil || r.StatusCode < 200 || r.StatusCode >= 300
+}
+
+func parseAuthChallenges(header http.Header) []authChallenge {
+	var result []authChallenge
+
+	for _, value := range header.Values("WWW-Authenticate") {
+		for _, token := range splitChallengeTokens(value) {
+			first, rest, _ := strings.Cut(token, " ")
+
+			if first != "" && !strings.Contains(first, "=") {
+				result = append(result, authChallenge{Scheme: first, Params: map[string]string{}})
+				token = strings.TrimSpace(rest)
+			}
+
+			if len(result) == 0 {
+				continue
+			}
+
+			name, param, ok := strings.Cut(token, "=")
+			if !ok {
+				continue
+			}
+
+			name = strings.ToLower(strings.TrimSpace(name))
+			param = strings.Trim(strings.TrimSpace(param), `"`)
+
+			result[len(result)-1].Params[name] = param
+			if name == "realm" {
+				result[len(result)-1].Realm = param
+			}
+		}
+	}
+
+	return result
+}
+
+func splitChallengeTokens(value string) []string {
+	var tokens []string
+	var quoted bool
+
+	start := 0
+	for i, r := range value {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ',' && !quoted:
+			tokens = append(tokens, strings.TrimSpace(value[start:i]))
+			start = i + 1
+		}
+	}
+
+	tokens = append(tokens, strings.TrimSpace(value[start:]))
+
+	return tokens
+}
+
+// verifyAuthChallenge checks that a 401 response really comes from HTTP authentication:
+// there must be a Basic or Digest challenge, and its realm must match expectedRealm if one is set.
+func verifyAuthChallenge(header http.Header, expectedRealm string) (authChallenge, error) {
+	var realms []string
+
+	for _, challenge := range parseAuthChallenges(header) {
+		if !strings.EqualFold(challenge.Scheme, "Basic") && !strings.EqualFold(challenge.Scheme, "Digest") {
+			continue
+		}
+
+		if expectedRealm == "" || challenge.Realm == expectedRealm {
+			return challenge, nil
+		}
+
+		realms = append(realms, challenge.Realm)
+	}
+
+	if len(realms) == 0 {
+		return authChallenge{}, errNoAuthChallenge
+	}
+
+	return authChallenge{}, fmt.Errorf("unexpected realm %q, expected %q", strings.Join(realms, ", "), expectedRealm)
+}
+
+// checkCredentials repeats the probe of a closed site with its credentials, the site behind the auth must answer 2xx.
+// The client must verify the site certificate, an https site with an untrusted certificate doesn't get the credentials.
+func checkCredentials(ctx context.Context, client *http.Client, credentials *config.Credentials, probe *ProbeResult) error {
+	result := &AuthResult{}
+	probe.Authenticated = result
+
+	challenge, err := verifyAuthChallenge(probe.Header, "")
+	if err != nil {
+		result.Err = err
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, probe.Probe.Method, probe.FinalURL(), nil)
+	if err != nil {
+		result.Err = err
+		return nil
+	}
+
+	setHeaders(req, probe.Probe.Headers)
+
+	authorization, err := authorizationHeader(challenge, credentials, req.Method, req.URL.RequestURI())
+	if err != nil {
+		result.Err = err
+		return nil
+	}
+
+	req.Header.Set("Authorization", authorization)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		result.Err = err
+		return nil
+	}
+
+	resp.Body.Close()
+	result.StatusCode = resp.StatusCode
+
+	return nil
+}
+
+// authorizationHeader answers the challenge with the credentials, Basic is used for all schemes except Digest.
+func authorizationHeader(challenge authChallenge, credentials *config.Credentials, method string, uri string) (string, error) {
+	if strings.EqualFold(challenge.Scheme, "Digest") {
+		return digestAuthorization(challenge, credentials, method, uri)
+	}
+
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials.Username+":"+credentials.Password)), nil
+}
+
+func digestAuthorization(challenge authChallenge, credentials *config.Credentials, method string, uri string) (string, error) {
+	algorithm := challenge.Params["algorithm"]
+	if algorithm != "" && !strings.EqualFold(algorithm, "MD5") {
+		return "", fmt.Errorf("unsupported digest algorithm %s", algorithm)
+	}
+
+	nonce := challenge.Params["nonce"]
+	if nonce == "" {
+		return "", errors.New("digest challenge without nonce")
+	}
+
+	ha1 := md5Hex(fmt.Sprintf("%s:%s:%s", credentials.Username, challenge.Realm, credentials.Password))
+	ha2 := md5Hex(fmt.Sprintf("%s:%s", method, uri))
+
+	params := []string{
+		fmt.Sprintf(`username="%s"`, credentials.Username),
+		fmt.Sprintf(`realm="%s"`, challenge.Realm),
+		fmt.Sprintf(`nonce="%s"`, nonce),
+		fmt.Sprintf(`uri="%s"`, uri),
+	}
+
+	if qop, ok := challenge.Params["qop"]; ok {
+		if !slices.ContainsFunc(strings.Split(qop, ","), func(value string) bool { return strings.TrimSpace(value) == "auth" }) {
+			return "", fmt.Errorf("unsupported digest qop %s", qop)
+		}
+
+		cnonce := rand.Text()
+		response := md5Hex(fmt.Sprintf("%s:%s:00000001:%s:auth:%s", ha1, nonce, cnonce, ha2))
+		params = append(params, "qop=auth", "nc=00000001", fmt.Sprintf(`cnonce="%s"`, cnonce), fmt.Sprintf(`response="%s"`, response))
+	} else {
+		params = append(params, fmt.Sprintf(`response="%s"`, md5Hex(fmt.Sprintf("%s:%s:%s", ha1, nonce, ha2))))
+	}
+
+	if opaque, ok := challenge.Params["opaque"]; ok {
+		params = append(params, fmt.Sprintf(`opaque="%s"`, opaque))
+	}
+
+	if algorithm != "" {
+		params = append(params, "algorithm="+algorithm)
+	}
+
+	return "Digest " + strings.Join(params, ", "), nil
+}
+
+func md5Hex(value string) string {
+	sum := md5.Sum([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,224 @@
+package checker
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAuthChallenges(t *testing.T) {
+	testCases := []struct {
+		name     string
+		values   []string
+		expected []authChallenge
+	}{
+		{
+			name:     "basic",
+			values:   []string{`Basic realm="Restricted area"`},
+			expected: []authChallenge{{Scheme: "Basic", Realm: "Restricted area", Params: map[string]string{"realm": "Restricted area"}}},
+		},
+		{
+			name:     "digest with params",
+			values:   []string{`Digest realm="staging", qop="auth,auth-int", nonce="abc"`},
+			expected: []authChallenge{{Scheme: "Digest", Realm: "staging", Params: map[string]string{"realm": "staging", "qop": "auth,auth-int", "nonce": "abc"}}},
+		},
+		{
+			name:   "several challenges in one header",
+			values: []string{`Negotiate, Basic realm="a, b", charset="UTF-8"`},
+			expected: []authChallenge{
+				{Scheme: "Negotiate", Params: map[string]string{}},
+				{Scheme: "Basic", Realm: "a, b", Params: map[string]string{"realm": "a, b", "charset": "UTF-8"}},
+			},
+		},
+		{
+			name:     "several headers",
+			values:   []string{`Bearer`, `basic realm=test`},
+			expected: []authChallenge{{Scheme: "Bearer", Params: map[string]string{}}, {Scheme: "basic", Realm: "test", Params: map[string]string{"realm": "test"}}},
+		},
+		{
+			name:   "no header",
+			values: nil,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			header := http.Header{}
+			for _, value := range testCase.values {
+				header.Add("WWW-Authenticate", value)
+			}
+
+			assert.Equal(t, testCase.expected, parseAuthChallenges(header))
+		})
+	}
+}
+
+func TestVerifyAuthChallenge(t *testing.T) {
+	header := http.Header{}
+	header.Add("WWW-Authenticate", `Bearer realm="api"`)
+
+	_, err := verifyAuthChallenge(header, "")
+	assert.ErrorIs(t, err, errNoAuthChallenge)
+
+	header.Add("WWW-Authenticate", `Basic realm="Staging"`)
+
+	challenge, err := verifyAuthChallenge(header, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "Basic", challenge.Scheme)
+	assert.Equal(t, "Staging", challenge.Realm)
+
+	_, err = verifyAuthChallenge(header, "Staging")
+	assert.NoError(t, err)
+
+	_, err = verifyAuthChallenge(header, "Production")
+	assert.EqualError(t, err, `unexpected realm "Staging", expected "Production"`)
+}
+
+func TestCheckCredentialsBasic(t *testing.T) {
+	testCases := []struct {
+		name     string
+		password string
+		status   int
+		expected *AuthResult
+	}{
+		{name: "works behind auth", password: "secret", status: http.StatusOK, expected: &AuthResult{StatusCode: http.StatusOK}},
+		{name: "broken behind auth", password: "secret", status: http.StatusInternalServerError, expected: &AuthResult{StatusCode: http.StatusInternalServerError}},
+		{name: "wrong password", password: "wrong", status: http.StatusOK, expected: &AuthResult{StatusCode: http.StatusUnauthorized}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				username, password, ok := r.BasicAuth()
+				if !ok || username != "user" || password != "secret" {
+					w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+
+				w.WriteHeader(testCase.status)
+			}))
+			defer server.Close()
+
+			serverURL, _ := url.Parse(server.URL)
+
+			probe := &ProbeResult{
+				Probe:      config.ProbesDefault[0],
+				URL:        "http://example.com/",
+				StatusCode: http.StatusUnauthorized,
+				Header:     http.Header{"Www-Authenticate": []string{`Basic realm="Restricted"`}},
+			}
+			credentials := &config.Credentials{Site: site, Username: "user", Password: testCase.password}
+
+			client := newClientSet(newTransportPool(config.Pool{}, nil, nil), serverURL.Hostname(), config.Timeouts{}).get(serverURL.Port())
+
+			assert.NoError(t, checkCredentials(t.Context(), client, credentials, probe))
+			assert.Equal(t, testCase.expected, probe.Authenticated)
+		})
+	}
+}
+
+func TestCheckCredentialsUntrustedCertificate(t *testing.T) {
+	var authorized atomic.Bool
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			authorized.Store(true)
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+
+	probe := &ProbeResult{
+		Probe:      config.ProbesDefault[0],
+		URL:        "https://example.com/",
+		StatusCode: http.StatusUnauthorized,
+		Header:     http.Header{"Www-Authenticate": []string{`Basic realm="Restricted"`}},
+	}
+	credentials := &config.Credentials{Site: site, Username: "user", Password: "secret"}
+
+	clients := newClientSet(newTransportPool(config.Pool{}, nil, nil), serverURL.Hostname(), config.Timeouts{})
+
+	assert.NoError(t, checkCredentials(t.Context(), clients.verified(serverURL.Port()), credentials, probe))
+	assert.ErrorContains(t, probe.Authenticated.Err, "certificate")
+	assert.False(t, authorized.Load(), "the credentials are not sent to an untrusted server")
+}
+
+func TestCheckCredentialsDigest(t *testing.T) {
+	const (
+		realm = "Staging"
+		nonce = "dcd98b7102dd2f0e8b11d0f600bfb0c093"
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := http.Header{"Www-Authenticate": []string{r.Header.Get("Authorization")}}
+		challenges := parseAuthChallenges(header)
+
+		if len(challenges) == 1 && challenges[0].Scheme == "Digest" {
+			params := challenges[0].Params
+			ha1 := md5Hex("user:" + realm + ":secret")
+			ha2 := md5Hex(r.Method + ":" + params["uri"])
+			expected := md5Hex(fmt.Sprintf("%s:%s:%s:%s:%s:%s", ha1, nonce, params["nc"], params["cnonce"], params["qop"], ha2))
+
+			if params["response"] == expected && params["opaque"] == "xyz" {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+
+	probe := &ProbeResult{
+		Probe:      config.ProbesDefault[0],
+		URL:        "http://example.com/",
+		StatusCode: http.StatusUnauthorized,
+		Header:     http.Header{"Www-Authenticate": []string{`Digest realm="` + realm + `", qop="auth", nonce="` + nonce + `", opaque="xyz"`}},
+	}
+	credentials := &config.Credentials{Site: site, Username: "user", Password: "secret"}
+
+	client := newClientSet(newTransportPool(config.Pool{}, nil, nil), serverURL.Hostname(), config.Timeouts{}).get(serverURL.Port())
+
+	assert.NoError(t, checkCredentials(t.Context(), client, credentials, probe))
+	assert.Equal(t, &AuthResult{StatusCode: http.StatusOK}, probe.Authenticated)
+}
+
+func TestDigestAuthorizationQop(t *testing.T) {
+	credentials := &config.Credentials{Site: site, Username: "user", Password: "secret"}
+
+	testCases := []struct {
+		qop      string
+		expected string
+	}{
+		{qop: "auth", expected: "qop=auth"},
+		{qop: "auth-int, auth", expected: "qop=auth"},
+		{qop: "auth-int"},
+		{qop: "authentication"},
+	}
+
+	for _, testCase := range testCases {
+		challenge := authChallenge{Scheme: "Digest", Realm: "Staging", Params: map[string]string{"nonce": "abc", "qop": testCase.qop}}
+
+		authorization, err := digestAuthorization(challenge, credentials, http.MethodGet, "/")
+		if testCase.expected == "" {
+			assert.EqualError(t, err, "unsupported digest qop "+testCase.qop)
+			continue
+		}
+
+		assert.NoError(t, err)
+		assert.Contains(t, authorization, testCase.expected)
+	}
+}
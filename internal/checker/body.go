@@ -0,0 +1,60 @@
+package checker
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+)
+
+const snippetSize = 80
+
+// readBody reads at most limit bytes of the body, truncated is set when the body is longer.
+func readBody(body io.Reader, limit int64) (data []byte, truncated bool, err error) {
+	data, err = io.ReadAll(io.LimitReader(body, limit+1))
+	if int64(len(data)) > limit {
+		return data[:limit], true, err
+	}
+
+	return data, false, err
+}
+
+func checkBody(probe config.Probe, body []byte, truncated bool) string {
+	size := int64(len(body))
+
+	if probe.MinSize > 0 && size < probe.MinSize {
+		return fmt.Sprintf("размер ответа %d байт меньше минимального %d", size, probe.MinSize)
+	}
+
+	if probe.MaxSize > 0 && (truncated || size > probe.MaxSize) {
+		if truncated {
+			return fmt.Sprintf("размер ответа больше %d байт, максимальный %d", size, probe.MaxSize)
+		}
+
+		return fmt.Sprintf("размер ответа %d байт больше максимального %d", size, probe.MaxSize)
+	}
+
+	for _, re := range probe.BodyContainsRegexps {
+		if !re.Match(body) {
+			return fmt.Sprintf("не найдено совпадение с %q", re.String())
+		}
+	}
+
+	for _, re := range probe.BodyNotContainsRegexps {
+		if loc := re.FindIndex(body); loc != nil {
+			return fmt.Sprintf("найдено совпадение с %q: %q", re.String(), snippet(body, loc))
+		}
+	}
+
+	return ""
+}
+
+func snippet(body []byte, loc []int) string {
+	start, end := loc[0], loc[1]
+
+	if end-start > snippetSize {
+		end = start + snippetSize
+	}
+
+	return string(body[start:end])
+}
@@ -0,0 +1,85 @@
+package checker
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadBody(t *testing.T) {
+	body, truncated, err := readBody(strings.NewReader("hello"), 10)
+	assert.NoError(t, err)
+	assert.False(t, truncated)
+	assert.Equal(t, []byte("hello"), body)
+
+	body, truncated, err = readBody(strings.NewReader("hello"), 5)
+	assert.NoError(t, err)
+	assert.False(t, truncated)
+	assert.Equal(t, []byte("hello"), body)
+
+	body, truncated, err = readBody(strings.NewReader("hello world"), 5)
+	assert.NoError(t, err)
+	assert.True(t, truncated)
+	assert.Equal(t, []byte("hello"), body)
+}
+
+func TestCheckBody(t *testing.T) {
+	testCases := []struct {
+		name      string
+		probe     config.Probe
+		body      string
+		truncated bool
+		expected  string
+	}{
+		{
+			name:  "no assertions",
+			probe: config.Probe{},
+			body:  "anything",
+		},
+		{
+			name:  "must contain found",
+			probe: config.Probe{BodyContainsRegexps: []*regexp.Regexp{regexp.MustCompile(`(?i)<title>.*shop`)}},
+			body:  "<html><title>My Shop</title></html>",
+		},
+		{
+			name:     "must contain not found",
+			probe:    config.Probe{BodyContainsRegexps: []*regexp.Regexp{regexp.MustCompile(`</html>`)}},
+			body:     "<html><body>",
+			expected: `не найдено совпадение с "</html>"`,
+		},
+		{
+			name:     "must not contain found",
+			probe:    config.Probe{BodyNotContainsRegexps: []*regexp.Regexp{regexp.MustCompile(`Account suspended`)}},
+			body:     "<h1>Account suspended</h1>",
+			expected: `найдено совпадение с "Account suspended": "Account suspended"`,
+		},
+		{
+			name:     "too small",
+			probe:    config.Probe{MinSize: 100},
+			body:     "stub",
+			expected: "размер ответа 4 байт меньше минимального 100",
+		},
+		{
+			name:     "too big",
+			probe:    config.Probe{MaxSize: 3},
+			body:     "stub",
+			expected: "размер ответа 4 байт больше максимального 3",
+		},
+		{
+			name:      "truncated",
+			probe:     config.Probe{MaxSize: 3},
+			body:      "stub",
+			truncated: true,
+			expected:  "размер ответа больше 4 байт, максимальный 3",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, checkBody(testCase.probe, []byte(testCase.body), testCase.truncated))
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package checker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+	"github.com/kias-hack/isp-site-checker/internal/isp"
+)
+
+const (
+	foreignHost      = "default.invalid"
+	bypassBodyLimit  = 512 * 1024
+	defaultHTTPPort  = "80"
+	defaultHTTPSPort = "443"
+)
+
+var errNotSiteContent = errors.New("response does not look like the site content")
+
+type BypassProbe struct {
+	Method string
+	Path   string
+	// Scheme, Port and Host override the site values when not empty
+	Scheme string
+	Port   string
+	Host   string
+	// MatchSite requires the response to look like the site content, used for the probes which may reach
+	// another vhost: the other port and the default vhost
+	MatchSite bool
+}
+
+type BypassResult struct {
+	Method     string
+	URL        string
+	Addr       string
+	StatusCode int
+	Err        error
+}
+
+func (r BypassResult) Bypassed() bool {
+	return r.Err == nil && r.StatusCode >= 200 && r.StatusCode < 300
+}
+
+func buildBypassProbes(cfg *config.Config, domain *isp.WebDomain) []BypassProbe {
+	var probes []BypassProbe
+
+	if cfg.BypassAudit.Enabled {
+		for _, method := range cfg.BypassAudit.Methods {
+			probes = append(probes, BypassProbe{Method: strings.ToUpper(method), Path: "/"})
+		}
+
+		for _, path := range cfg.BypassAudit.Paths {
+			probes = append(probes, BypassProbe{Method: http.MethodGet, Path: path})
+		}
+	}
+
+	if cfg.BypassAudit.CrossPort {
+		port := otherPort(domain.Port)
+
+		probes = append(probes, BypassProbe{Method: http.MethodGet, Path: "/", Scheme: schemeForPort(port), Port: port, MatchSite: true})
+	}
+
+	if cfg.BypassAudit.DefaultVhost {
+		for _, host := range []string{domain.IPAddr, foreignHost} {
+			probes = append(probes, BypassProbe{Method: http.MethodGet, Path: "/", Host: host, MatchSite: true})
+		}
+	}
+
+	return probes
+}
+
+// auditBypass sends the audit requests of a closed site, any of them answering with content means auth can be bypassed.
+func auditBypass(ctx context.Context, clients *clientSet, task *Task) error {
+	for _, probe := range task.BypassProbes {
+		scheme, port, host := schemeForPort(task.Connection.Port), task.Connection.Port, task.Site
+
+		if probe.Scheme != "" {
+			scheme = probe.Scheme
+		}
+
+		if probe.Host != "" {
+			host = probe.Host
+		}
+
+		result := BypassResult{
+			Method: probe.Method,
+			URL:    fmt.Sprintf("%s://%s%s", scheme, host, probe.Path),
+		}
+
+		if probe.Port != "" {
+			port = probe.Port
+		}
+
+		if port != task.Connection.Port || probe.Host != "" {
+			result.Addr = net.JoinHostPort(task.Connection.Addr, port)
+		}
+
+		if err := sendBypassRequest(ctx, clients.get(port), probe, task, &result); err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		task.Result.Bypass = append(task.Result.Bypass, result)
+	}
+
+	return nil
+}
+
+func sendBypassRequest(ctx context.Context, client *http.Client, probe BypassProbe, task *Task, result *BypassResult) error {
+	req, err := http.NewRequestWithContext(ctx, probe.Method, result.URL, nil)
+	if err != nil {
+		result.Err = err
+		return err
+	}
+
+	setHeaders(req, task.Headers)
+
+	// the default vhost probes must not get the site name from a configured Host header
+	if probe.Host != "" {
+		req.Host = ""
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		result.Err = err
+		return err
+	}
+	defer resp.Body.Close()
+
+	result.StatusCode = resp.StatusCode
+
+	if !probe.MatchSite || !result.Bypassed() {
+		return nil
+	}
+
+	body, _, err := readBody(resp.Body, bypassBodyLimit)
+	if err != nil {
+		result.Err = err
+		return err
+	}
+
+	if !strings.Contains(strings.ToLower(string(body)), strings.ToLower(task.Site)) {
+		result.Err = errNotSiteContent
+	}
+
+	return nil
+}
+
+func bypassReport(results []BypassResult) string {
+	var lines []string
+
+	for _, result := range results {
+		if !result.Bypassed() {
+			continue
+		}
+
+		if result.Addr != "" {
+			lines = append(lines, fmt.Sprintf("%s %s (%s) - %d", result.Method, result.URL, result.Addr, result.StatusCode))
+		} else {
+			lines = append(lines, fmt.Sprintf("%s %s - %d", result.Method, result.URL, result.StatusCode))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func schemeForPort(port string) string {
+	if port == defaultHTTPSPort {
+		return "https"
+	}
+
+	return "http"
+}
+
+func otherPort(port string) string {
+	if port == defaultHTTPSPort {
+		return defaultHTTPPort
+	}
+
+	return defaultHTTPSPort
+}
@@ -0,0 +1,132 @@
+package checker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+	"github.com/kias-hack/isp-site-checker/internal/isp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildBypassProbes(t *testing.T) {
+	cfg := &config.Config{}
+	domain := &isp.WebDomain{IPAddr: host, Port: "80"}
+
+	assert.Nil(t, buildBypassProbes(cfg, domain))
+
+	cfg.BypassAudit.Enabled = true
+	cfg.BypassAudit.Methods = []string{"post", "OPTIONS"}
+	cfg.BypassAudit.Paths = []string{"/index.php"}
+
+	assert.Equal(t, []BypassProbe{
+		{Method: http.MethodPost, Path: "/"},
+		{Method: http.MethodOptions, Path: "/"},
+		{Method: http.MethodGet, Path: "/index.php"},
+	}, buildBypassProbes(cfg, domain))
+
+	cfg.BypassAudit.Enabled = false
+	cfg.BypassAudit.CrossPort = true
+	cfg.BypassAudit.DefaultVhost = true
+
+	assert.Equal(t, []BypassProbe{
+		{Method: http.MethodGet, Path: "/", Scheme: "https", Port: "443", MatchSite: true},
+		{Method: http.MethodGet, Path: "/", Host: host, MatchSite: true},
+		{Method: http.MethodGet, Path: "/", Host: foreignHost, MatchSite: true},
+	}, buildBypassProbes(cfg, domain))
+
+	domain.Port = "443"
+
+	assert.Equal(t, BypassProbe{Method: http.MethodGet, Path: "/", Scheme: "http", Port: "80", MatchSite: true}, buildBypassProbes(cfg, domain)[0])
+}
+
+func TestAuditBypass(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost || r.URL.Path == "/robots.txt" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+
+	task := &Task{
+		Site: site,
+		BypassProbes: []BypassProbe{
+			{Method: http.MethodPost, Path: "/"},
+			{Method: http.MethodOptions, Path: "/"},
+			{Method: http.MethodGet, Path: "/robots.txt"},
+			{Method: http.MethodGet, Path: "/index.php"},
+		},
+	}
+	task.Connection.Addr = serverURL.Hostname()
+	task.Connection.Port = serverURL.Port()
+
+	assert.NoError(t, auditBypass(t.Context(), newClientSet(newTransportPool(config.Pool{}, nil, nil), serverURL.Hostname(), config.Timeouts{}), task))
+	assert.Equal(t, []BypassResult{
+		{Method: http.MethodPost, URL: "http://example.com/", StatusCode: http.StatusOK},
+		{Method: http.MethodOptions, URL: "http://example.com/", StatusCode: http.StatusUnauthorized},
+		{Method: http.MethodGet, URL: "http://example.com/robots.txt", StatusCode: http.StatusOK},
+		{Method: http.MethodGet, URL: "http://example.com/index.php", StatusCode: http.StatusUnauthorized},
+	}, task.Result.Bypass)
+
+	assert.Equal(t, "POST http://example.com/ - 200\nGET http://example.com/robots.txt - 200", bypassReport(task.Result.Bypass))
+}
+
+func TestAuditBypassCrossPortAndDefaultVhost(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Host {
+		case site:
+			w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		case foreignHost:
+			w.Write([]byte(`<a href="http://example.com/catalog/">Каталог</a>`))
+		default:
+			w.Write([]byte("ISPmanager default page"))
+		}
+	}))
+	defer server.Close()
+
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<title>example.com</title>"))
+	}))
+	defer tlsServer.Close()
+
+	// the other port served by the default vhost is not a bypass
+	otherTLSServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Welcome to nginx!"))
+	}))
+	defer otherTLSServer.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	tlsServerURL, _ := url.Parse(tlsServer.URL)
+	otherTLSServerURL, _ := url.Parse(otherTLSServer.URL)
+
+	task := &Task{
+		Site: site,
+		BypassProbes: []BypassProbe{
+			{Method: http.MethodGet, Path: "/", Scheme: "https", Port: tlsServerURL.Port(), MatchSite: true},
+			{Method: http.MethodGet, Path: "/", Scheme: "https", Port: otherTLSServerURL.Port(), MatchSite: true},
+			{Method: http.MethodGet, Path: "/", Host: host, MatchSite: true},
+			{Method: http.MethodGet, Path: "/", Host: foreignHost, MatchSite: true},
+		},
+	}
+	task.Connection.Addr = serverURL.Hostname()
+	task.Connection.Port = serverURL.Port()
+
+	assert.NoError(t, auditBypass(t.Context(), newClientSet(newTransportPool(config.Pool{}, nil, nil), serverURL.Hostname(), config.Timeouts{}), task))
+	assert.Equal(t, []BypassResult{
+		{Method: http.MethodGet, URL: "https://example.com/", Addr: tlsServerURL.Host, StatusCode: http.StatusOK},
+		{Method: http.MethodGet, URL: "https://example.com/", Addr: otherTLSServerURL.Host, StatusCode: http.StatusOK, Err: errNotSiteContent},
+		{Method: http.MethodGet, URL: "http://127.0.0.1/", Addr: serverURL.Host, StatusCode: http.StatusOK, Err: errNotSiteContent},
+		{Method: http.MethodGet, URL: "http://default.invalid/", Addr: serverURL.Host, StatusCode: http.StatusOK},
+	}, task.Result.Bypass)
+
+	assert.Equal(t, "GET https://example.com/ ("+tlsServerURL.Host+") - 200\nGET http://default.invalid/ ("+serverURL.Host+") - 200", bypassReport(task.Result.Bypass))
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"regexp"
 	"sync"
 	"time"
 
@@ -12,22 +13,75 @@ import (
 	"github.com/kias-hack/isp-site-checker/internal/notify"
 )
 
-const workerPoolCountDefault int = 10
-
 type Task struct {
 	DomainId   int
 	Owner      string
 	DomainName string
 	Site       string
+	PHPVersion string
+	PHPHandler string
 	Connection struct {
 		Addr string
 		Port string
 	}
-	Result struct {
-		StatusCode int
-		Err        error
-		Timestamp  time.Time
+	Probes      []config.Probe
+	MaxBodySize int64
+	Latency     config.LatencyThresholds
+	// ErrorSignatures are searched in bodies of probes expecting an open site
+	ErrorSignatures []*regexp.Regexp
+	AuthRealm       string
+	Credentials     *config.Credentials
+	BypassProbes    []BypassProbe
+	Redirects       config.RedirectPolicy
+	Timeouts        config.Timeouts
+	Failure         config.FailurePolicy
+	// Headers are sent with every request to the site, the probe headers override them
+	Headers map[string]string
+	// PublicPath enables the check of closed probes through the public path
+	PublicPath bool
+	// DNSResolver is the address of the resolver for DNS probes, the system resolver when empty
+	DNSResolver string
+	// Round is the scheduler round the task belongs to, nil for tasks outside of rounds
+	Round *round
+	// Scheduled is the site with its own schedule the task belongs to, nil for the round tasks
+	Scheduled *scheduledSite
+	// Recheck marks the extra check of a failing site between the rounds
+	Recheck bool
+	// SensitiveFiles are requested by the task when the site is due for the sensitive files audit
+	SensitiveFiles []config.SensitiveFile
+	// ListingPaths are requested by the task when the site is due for the directory listing audit
+	ListingPaths []string
+	// NoIndex makes the task audit the search engine protection of a non-production site
+	NoIndex bool
+	// Audits is the audit schedule which marked the task, nil for the tasks without audits
+	Audits *audits
+	// ServerSites are the sites served by the same address, redirects to them stay on the server
+	ServerSites map[string]bool
+	Result      Result
+}
+
+type Result struct {
+	Probes    []ProbeResult
+	Bypass    []BypassResult
+	Sensitive []SensitiveResult
+	Listings  []ListingResult
+	NoIndex   *NoIndexResult
+	Timestamp time.Time
+	// SensitiveAudited and ListingsAudited report that the audits were done, the closed sites are audited
+	// without requests
+	SensitiveAudited bool
+	ListingsAudited  bool
+}
+
+// Closed reports that at least one probe found the site closed, auth bypass audit makes sense only then.
+func (r *Result) Closed() bool {
+	for i := range r.Probes {
+		if r.Probes[i].Closed() {
+			return true
+		}
 	}
+
+	return false
 }
 
 type Checker struct {
@@ -42,6 +96,9 @@ type Checker struct {
 	schedTicker chan struct{}
 
 	getDomains isp.GetWebDomainsFunc
+	transports *transportPool
+	rechecks   *recheckQueue
+	siteList   *siteList
 
 	notifier notify.Notifier
 }
@@ -67,9 +124,12 @@ func (c *Checker) Start() error {
 	c.taskPipe = make(chan *Task)
 	c.resultPipe = make(chan *Task)
 	c.schedTicker = make(chan struct{})
+	c.transports = newTransportPool(c.config.Pool, newLimiterSet(c.config.ServerLimits), newOutbound(c.config.Outbound))
+	c.rechecks = newRecheckQueue(c.config.Recheck)
+	c.siteList = newSiteList()
 
 	c.wg.Add(3)
-	go resultHandler(ctx, c.wg, c.resultPipe, c.notifier)
+	go resultHandler(ctx, c.wg, c.resultPipe, c.notifier, c.rechecks, c.siteList)
 
 	go func() {
 		defer c.wg.Done()
@@ -79,6 +139,10 @@ func (c *Checker) Start() error {
 		for {
 			select {
 			case <-ticker.C:
+				if c.config.Pool.FreshPerRound {
+					c.transports.closeIdleConnections()
+				}
+
 				c.schedTicker <- struct{}{}
 			case <-c.ctx.Done():
 				return
@@ -86,11 +150,11 @@ func (c *Checker) Start() error {
 		}
 	}()
 
-	go scheduler(ctx, c.wg, c.schedTicker, c.taskPipe, c.getDomains)
+	go scheduler(ctx, c.wg, c.config, c.schedTicker, c.taskPipe, c.getDomains, c.notifier, c.rechecks, c.siteList)
 
-	for n := range workerPoolCountDefault {
+	for n := range c.config.Workers {
 		c.wg.Add(1)
-		go worker(c.ctx, c.wg, c.taskPipe, c.resultPipe, n)
+		go worker(c.ctx, c.wg, c.transports, c.taskPipe, c.resultPipe, n)
 	}
 
 	c.work = true
@@ -124,6 +188,7 @@ func (c *Checker) Stop(ctx context.Context) error {
 	var waitErr error
 	select {
 	case <-waitChan:
+		c.transports.closeIdleConnections()
 		c.work = false
 	case <-ctx.Done():
 		waitErr = fmt.Errorf("checker wait: %w", ctx.Err())
@@ -0,0 +1,118 @@
+package checker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+)
+
+// siteState keeps the recent check results of a site to confirm its failures and recoveries.
+type siteState struct {
+	history     []bool
+	failing     bool
+	passed      int
+	failMessage string
+}
+
+// record adds the check result and reports whether the site is declared failed.
+func (s *siteState) record(policy config.FailurePolicy, failed bool) bool {
+	threshold := max(policy.Threshold, 1)
+	window := max(policy.Window, threshold)
+
+	s.history = append(s.history, failed)
+	if len(s.history) > window {
+		s.history = s.history[len(s.history)-window:]
+	}
+
+	if failed {
+		s.passed = 0
+
+		if !s.failing && countFailed(s.history) >= threshold {
+			s.failing = true
+		}
+
+		return s.failing
+	}
+
+	if s.failing {
+		s.passed++
+
+		if s.passed >= max(policy.Recovery, 1) {
+			s.failing = false
+			s.passed = 0
+			s.history = nil
+		}
+	}
+
+	return s.failing
+}
+
+func countFailed(history []bool) int {
+	count := 0
+
+	for _, failed := range history {
+		if failed {
+			count++
+		}
+	}
+
+	return count
+}
+
+// runProbeWithRetries repeats the probe while it fails with a connection error, at most policy retries times.
+func runProbeWithRetries(ctx context.Context, probe Probe, target Target) (ProbeResult, error) {
+	task := target.Task
+	backoff := task.Failure.RetryBackoff
+
+	for attempt := 1; ; attempt++ {
+		result, err := probe.Run(ctx, target)
+		result.Attempts = attempt
+
+		if err != nil || !isConnectionError(result.Err) || attempt > task.Failure.Retries {
+			return result, err
+		}
+
+		timer := time.NewTimer(backoff)
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return result, ctx.Err()
+		}
+
+		backoff *= 2
+	}
+}
+
+// isConnectionError reports whether the request failed on the connection level: the dial, a reset or closed
+// connection, or a timeout. DNS lookup errors and the errors of the response content are not retried.
+func isConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return false
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+
+	var netErr net.Error
+
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
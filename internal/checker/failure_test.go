@@ -0,0 +1,149 @@
+package checker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSiteStateRecord(t *testing.T) {
+	testCases := []struct {
+		name     string
+		policy   config.FailurePolicy
+		results  []bool
+		expected []bool
+	}{
+		{
+			name:     "default policy",
+			policy:   config.FailurePolicy{},
+			results:  []bool{true, false, true},
+			expected: []bool{true, false, true},
+		},
+		{
+			name:     "three failures in a row",
+			policy:   config.FailurePolicy{Threshold: 3, Recovery: 1},
+			results:  []bool{true, true, false, true, true, true, true},
+			expected: []bool{false, false, false, false, false, true, true},
+		},
+		{
+			name:     "two failures of the last four",
+			policy:   config.FailurePolicy{Threshold: 2, Window: 4, Recovery: 1},
+			results:  []bool{true, false, false, false, true, false, true},
+			expected: []bool{false, false, false, false, false, false, true},
+		},
+		{
+			name:     "recovery after two passed checks",
+			policy:   config.FailurePolicy{Threshold: 1, Recovery: 2},
+			results:  []bool{true, false, true, false, false, false},
+			expected: []bool{true, true, true, true, false, false},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			state := &siteState{}
+
+			var actual []bool
+			for _, failed := range testCase.results {
+				actual = append(actual, state.record(testCase.policy, failed))
+			}
+
+			assert.Equal(t, testCase.expected, actual)
+		})
+	}
+}
+
+func TestRunProbeWithRetries(t *testing.T) {
+	var requests atomic.Int64
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= 2 {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+
+		w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+
+	task := &Task{Site: site, Failure: config.FailurePolicy{Retries: 2, RetryBackoff: 10 * time.Millisecond}}
+	task.Connection.Addr = serverURL.Hostname()
+	task.Connection.Port = serverURL.Port()
+
+	start := time.Now()
+	result, err := runProbeWithRetries(t.Context(), newProbe(config.ProbesDefault[0]), Target{Task: task, Clients: newClientSet(newTransportPool(config.Pool{}, nil, nil), task.Connection.Addr, config.Timeouts{})})
+
+	assert.NoError(t, err)
+	assert.NoError(t, result.Err)
+	assert.Equal(t, http.StatusUnauthorized, result.StatusCode)
+	assert.Equal(t, 3, result.Attempts)
+	assert.GreaterOrEqual(t, time.Since(start), 30*time.Millisecond)
+
+	requests.Store(0)
+	task.Failure.Retries = 1
+
+	result, err = runProbeWithRetries(t.Context(), newProbe(config.ProbesDefault[0]), Target{Task: task, Clients: newClientSet(newTransportPool(config.Pool{}, nil, nil), task.Connection.Addr, config.Timeouts{})})
+
+	assert.NoError(t, err)
+	assert.Error(t, result.Err)
+	assert.Equal(t, 2, result.Attempts)
+	assert.Contains(t, evaluateProbe(task, &result), "(попыток: 2)")
+}
+
+func TestRunProbeWithRetriesOnlyConnectionErrors(t *testing.T) {
+	var requests atomic.Int64
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Location", "http://[::1/")
+		w.WriteHeader(http.StatusFound)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+
+	task := &Task{Site: site, Failure: config.FailurePolicy{Retries: 2, RetryBackoff: 10 * time.Millisecond}}
+	task.Connection.Addr = serverURL.Hostname()
+	task.Connection.Port = serverURL.Port()
+
+	result, err := runProbeWithRetries(t.Context(), newProbe(config.ProbesDefault[0]), Target{Task: task, Clients: newClientSet(newTransportPool(config.Pool{}, nil, nil), task.Connection.Addr, config.Timeouts{})})
+
+	assert.NoError(t, err)
+	assert.ErrorContains(t, result.Err, "failed to parse Location header")
+	assert.Equal(t, 1, result.Attempts, "a broken redirect is not retried")
+	assert.Equal(t, int64(1), requests.Load())
+}
+
+func TestIsConnectionError(t *testing.T) {
+	testCases := []struct {
+		err      error
+		expected bool
+	}{
+		{err: &url.Error{Op: "Get", URL: "http://example.com/", Err: io.EOF}, expected: true},
+		{err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, expected: true},
+		{err: fmt.Errorf("read: %w", context.DeadlineExceeded), expected: true},
+		{err: &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "proxy.local", IsNotFound: true}}},
+		{err: &net.DNSError{Err: "server misbehaving", Name: "example.com"}},
+		{err: errors.New("invalid redirect location")},
+		{},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.expected, isConnectionError(testCase.err), testCase.err)
+	}
+}
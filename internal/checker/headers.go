@@ -0,0 +1,44 @@
+package checker
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+)
+
+// expandHeaders replaces {site}, {domain} and {owner} in the header values with the task fields.
+func expandHeaders(headers map[string]string, task *Task) map[string]string {
+	if len(headers) == 0 {
+		return nil
+	}
+
+	replacer := strings.NewReplacer("{site}", task.Site, "{domain}", task.DomainName, "{owner}", task.Owner)
+	result := make(map[string]string, len(headers))
+
+	for name, value := range headers {
+		result[http.CanonicalHeaderKey(name)] = replacer.Replace(value)
+	}
+
+	return result
+}
+
+// probeHeaders returns the task headers with the probe headers over them.
+func probeHeaders(task *Task, probe config.Probe) map[string]string {
+	own := expandHeaders(probe.Headers, task)
+	if len(task.Headers) == 0 {
+		return own
+	}
+
+	result := make(map[string]string, len(task.Headers)+len(own))
+
+	for name, value := range task.Headers {
+		result[name] = value
+	}
+
+	for name, value := range own {
+		result[name] = value
+	}
+
+	return result
+}
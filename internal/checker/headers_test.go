@@ -0,0 +1,52 @@
+package checker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProbeHeaders(t *testing.T) {
+	var received http.Header
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+
+	task := &Task{Site: site, DomainName: "example", Owner: "client1"}
+	task.Connection.Addr = serverURL.Hostname()
+	task.Connection.Port = serverURL.Port()
+	task.Headers = expandHeaders(map[string]string{
+		"user-agent":      "Checker/1.0 ({site})",
+		"Accept-Language": "ru-RU",
+		"Cookie":          "owner={owner}; domain={domain}",
+	}, task)
+
+	probe := config.Probe{
+		Path:    "/",
+		Method:  http.MethodPost,
+		Headers: map[string]string{"Accept-Language": "en-US", "X-Site": "{site}"},
+		Expect:  config.ExpectOpen,
+	}
+
+	result, err := runProbe(t.Context(), newClientSet(newTransportPool(config.Pool{}, nil, nil), task.Connection.Addr, config.Timeouts{}), task, probe)
+	require.NoError(t, err)
+	require.NoError(t, result.Err)
+
+	assert.Equal(t, "Checker/1.0 (example.com)", received.Get("User-Agent"))
+	assert.Equal(t, "en-US", received.Get("Accept-Language"))
+	assert.Equal(t, "owner=client1; domain=example", received.Get("Cookie"))
+	assert.Equal(t, "example.com", received.Get("X-Site"))
+	assert.Equal(t, map[string]string{"X-Site": "{site}", "Accept-Language": "en-US"}, probe.Headers, "config probe is not changed")
+
+	assert.Nil(t, probeHeaders(&Task{}, config.Probe{}))
+}
@@ -0,0 +1,132 @@
+package checker
+
+import (
+	"context"
+	"expvar"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+)
+
+var (
+	limitWaitSeconds = expvar.NewMap("limit_wait_seconds")
+	limitWaits       = expvar.NewMap("limit_waits")
+)
+
+// limiterSet keeps request limiters by server IP address.
+type limiterSet struct {
+	limits func(addr string) config.Limits
+
+	mu       sync.Mutex
+	limiters map[string]*serverLimiter
+}
+
+func newLimiterSet(limits func(addr string) config.Limits) *limiterSet {
+	return &limiterSet{
+		limits:   limits,
+		limiters: make(map[string]*serverLimiter),
+	}
+}
+
+func (s *limiterSet) get(addr string) *serverLimiter {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	limiter, ok := s.limiters[addr]
+	if !ok {
+		limiter = newServerLimiter(addr, s.limits(addr))
+		s.limiters[addr] = limiter
+	}
+
+	return limiter
+}
+
+// serverLimiter bounds the in-flight requests to a server and spaces them according to the allowed rate.
+type serverLimiter struct {
+	addr     string
+	slots    chan struct{}
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+func newServerLimiter(addr string, limits config.Limits) *serverLimiter {
+	limiter := &serverLimiter{addr: addr}
+
+	if limits.MaxInFlight > 0 {
+		limiter.slots = make(chan struct{}, limits.MaxInFlight)
+	}
+
+	if limits.RPS > 0 {
+		limiter.interval = time.Duration(float64(time.Second) / float64(limits.RPS))
+	}
+
+	return limiter
+}
+
+// wait blocks until the request is allowed, the returned function frees the in-flight slot.
+func (l *serverLimiter) wait(ctx context.Context) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	start := time.Now()
+	release := func() {}
+
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		release = sync.OnceFunc(func() { <-l.slots })
+	}
+
+	if l.interval > 0 {
+		l.mu.Lock()
+		at := time.Now()
+		if l.next.After(at) {
+			at = l.next
+		}
+		l.next = at.Add(l.interval)
+		l.mu.Unlock()
+
+		if delay := time.Until(at); delay > 0 {
+			timer := time.NewTimer(delay)
+			defer timer.Stop()
+
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				release()
+				return nil, ctx.Err()
+			}
+		}
+	}
+
+	if waited := time.Since(start); waited > time.Millisecond {
+		limitWaitSeconds.AddFloat(l.addr, waited.Seconds())
+		limitWaits.Add(l.addr, 1)
+	}
+
+	return release, nil
+}
+
+// releaseBody frees the limiter slot when the response body is closed.
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releaseBody) Close() error {
+	defer b.release()
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,149 @@
+package checker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiterMaxInFlight(t *testing.T) {
+	var current, peak atomic.Int64
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := current.Add(1)
+		defer current.Add(-1)
+
+		for {
+			old := peak.Load()
+			if n <= old || peak.CompareAndSwap(old, n) {
+				break
+			}
+		}
+
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+
+	pool := newTransportPool(config.Pool{}, newLimiterSet(func(addr string) config.Limits {
+		return config.Limits{MaxInFlight: 2}
+	}), nil)
+
+	wg := sync.WaitGroup{}
+	for range 6 {
+		wg.Go(func() {
+			clients := newClientSet(pool, serverURL.Hostname(), config.Timeouts{})
+			_, err := sendRequest(t.Context(), clients.get(serverURL.Port()), http.MethodGet, "http://example.com/", nil, 1024)
+			assert.NoError(t, err)
+		})
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(2), peak.Load())
+}
+
+func TestLimiterWaitIsNotCounted(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("OK"))
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+
+	pool := newTransportPool(config.Pool{}, newLimiterSet(func(addr string) config.Limits {
+		return config.Limits{MaxInFlight: 1}
+	}), nil)
+	timeouts := config.Timeouts{Total: 300 * time.Millisecond}
+	latency := config.LatencyThresholds{TTFB: 250 * time.Millisecond, Total: 250 * time.Millisecond}
+
+	// the last request waits for 300ms in the server queue
+	wg := sync.WaitGroup{}
+	for range 4 {
+		wg.Go(func() {
+			clients := newClientSet(pool, serverURL.Hostname(), timeouts)
+			result, err := sendRequest(t.Context(), clients.get(serverURL.Port()), http.MethodGet, "http://example.com/", nil, 1024)
+			if assert.NoError(t, err) {
+				assert.Empty(t, slowReason(latency, result.Timings))
+			}
+		})
+	}
+	wg.Wait()
+
+	task := &Task{Site: site, Timeouts: config.Timeouts{Total: 100 * time.Millisecond}}
+	task.Connection.Addr = serverURL.Hostname()
+
+	clients := newClientSet(pool, task.Connection.Addr, task.Timeouts)
+
+	release, err := clients.wait(t.Context())
+	require.NoError(t, err)
+	time.AfterFunc(200*time.Millisecond, release)
+
+	result, err := newProbe(config.Probe{Type: config.ProbeTCP, Port: serverURL.Port(), Expect: config.ExpectOpen}).Run(t.Context(), Target{Task: task, Clients: clients})
+	require.NoError(t, err)
+	assert.NoError(t, result.Err)
+	assert.True(t, result.Connected)
+	assert.Less(t, result.Timings.Connect, 100*time.Millisecond)
+}
+
+func TestLimiterRate(t *testing.T) {
+	limiter := newServerLimiter("192.0.2.1", config.Limits{RPS: 20})
+
+	start := time.Now()
+	for range 3 {
+		release, err := limiter.wait(t.Context())
+		assert.NoError(t, err)
+		release()
+	}
+
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	assert.Positive(t, limitWaitSeconds.Get("192.0.2.1").(interface{ Value() float64 }).Value())
+}
+
+func TestLimiterCancel(t *testing.T) {
+	limiter := newServerLimiter("192.0.2.2", config.Limits{MaxInFlight: 1})
+
+	release, err := limiter.wait(t.Context())
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err = limiter.wait(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	release()
+	release()
+
+	release, err = limiter.wait(t.Context())
+	assert.NoError(t, err)
+	release()
+}
+
+func TestLimiterSetByServer(t *testing.T) {
+	limiters := newLimiterSet(func(addr string) config.Limits {
+		if addr == "192.0.2.3" {
+			return config.Limits{MaxInFlight: 1}
+		}
+
+		return config.Limits{}
+	})
+
+	assert.Same(t, limiters.get("192.0.2.3"), limiters.get("192.0.2.3"))
+	assert.Equal(t, 1, cap(limiters.get("192.0.2.3").slots))
+	assert.Nil(t, limiters.get("192.0.2.4").slots)
+
+	var empty *limiterSet
+	assert.Nil(t, empty.get("192.0.2.3"))
+}
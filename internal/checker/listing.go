@@ -0,0 +1,159 @@
+package checker
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	listingBodyLimit = 256 * 1024
+	// listingEntriesShown is the number of the listed entries put in the notification
+	listingEntriesShown = 10
+)
+
+var (
+	// Apache, nginx and LiteSpeed autoindex pages are titled "Index of /path"
+	listingTitle = regexp.MustCompile(`(?i)<title>\s*Index of /`)
+	listingLink  = regexp.MustCompile(`(?i)<a\s+href="([^"]*)"`)
+)
+
+type ListingResult struct {
+	URL        string
+	StatusCode int
+	Listed     bool
+	Entries    []string
+	Err        error
+}
+
+// Found reports that the directory content is listed.
+func (r ListingResult) Found() bool {
+	return r.Err == nil && r.Listed
+}
+
+// auditListings requests the directories of an open site looking for autoindex pages. A closed site is audited
+// without requests, its directories are not listed to visitors. The audit stops at the first request error.
+func auditListings(ctx context.Context, clients *clientSet, task *Task) error {
+	task.Result.ListingsAudited = true
+
+	if task.Result.Closed() {
+		return nil
+	}
+
+	client := clients.get(task.Connection.Port)
+
+	for _, path := range task.ListingPaths {
+		result := ListingResult{
+			URL: fmt.Sprintf("%s://%s%s", schemeForPort(task.Connection.Port), task.Site, path),
+		}
+
+		err := sendListingRequest(ctx, client, task, &result)
+		if err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		task.Result.Listings = append(task.Result.Listings, result)
+
+		if err != nil {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func sendListingRequest(ctx context.Context, client *http.Client, task *Task, result *ListingResult) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, result.URL, nil)
+	if err != nil {
+		result.Err = err
+		return err
+	}
+
+	setHeaders(req, task.Headers)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		result.Err = err
+		return err
+	}
+	defer resp.Body.Close()
+
+	result.StatusCode = resp.StatusCode
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil
+	}
+
+	body, _, err := readBody(resp.Body, listingBodyLimit)
+	if err != nil {
+		result.Err = err
+		return err
+	}
+
+	result.Listed, result.Entries = parseListing(body)
+
+	return nil
+}
+
+// parseListing recognizes an autoindex page and returns its entries without the parent directory and sort links.
+func parseListing(body []byte) (bool, []string) {
+	if !listingTitle.Match(body) {
+		return false, nil
+	}
+
+	var entries []string
+
+	for _, match := range listingLink.FindAllSubmatch(body, -1) {
+		href := html.UnescapeString(string(match[1]))
+
+		if href == "" || strings.HasPrefix(href, "?") || strings.HasPrefix(href, "/") || strings.HasPrefix(href, "#") ||
+			strings.HasPrefix(href, "..") || strings.Contains(href, "://") {
+			continue
+		}
+
+		if name, err := url.PathUnescape(href); err == nil {
+			href = name
+		}
+
+		entries = append(entries, href)
+	}
+
+	return true, entries
+}
+
+// listingsFound returns the listed directories of the audited task, false when the audit was not done or failed.
+func listingsFound(task *Task) ([]string, bool) {
+	if !task.Result.ListingsAudited {
+		return nil, false
+	}
+
+	var found []string
+
+	for _, result := range task.Result.Listings {
+		if result.Err != nil {
+			return nil, false
+		}
+
+		if result.Found() {
+			found = append(found, fmt.Sprintf("%s - %s", result.URL, listingEntries(result.Entries)))
+		}
+	}
+
+	return found, true
+}
+
+func listingEntries(entries []string) string {
+	if len(entries) == 0 {
+		return "пустой каталог"
+	}
+
+	if len(entries) > listingEntriesShown {
+		return fmt.Sprintf("%s и ещё %d", strings.Join(entries[:listingEntriesShown], ", "), len(entries)-listingEntriesShown)
+	}
+
+	return strings.Join(entries, ", ")
+}
@@ -0,0 +1,156 @@
+package checker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+	"github.com/kias-hack/isp-site-checker/internal/notify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+const (
+	apacheListing = `<!DOCTYPE HTML PUBLIC "-//W3C//DTD HTML 3.2 Final//EN">
+<html>
+ <head>
+  <title>Index of /upload</title>
+ </head>
+ <body>
+<h1>Index of /upload</h1>
+  <table>
+   <tr><th><a href="?C=N;O=D">Name</a></th><th><a href="?C=M;O=A">Last modified</a></th></tr>
+<tr><td><a href="/">Parent Directory</a></td></tr>
+<tr><td><a href="backup%202024.zip">backup 2024.zip</a></td></tr>
+<tr><td><a href="invoices/">invoices/</a></td></tr>
+</table>
+</body></html>`
+
+	nginxListing = `<html>
+<head><title>Index of /</title></head>
+<body>
+<h1>Index of /</h1><hr><pre><a href="../">../</a>
+<a href="dump.sql">dump.sql</a>                                           15-Jan-2025 10:00     1048576
+<a href="a&amp;b.txt">a&amp;b.txt</a>                                    15-Jan-2025 10:00          12
+</pre><hr></body>
+</html>`
+)
+
+func TestParseListing(t *testing.T) {
+	testCases := []struct {
+		name    string
+		body    string
+		listed  bool
+		entries []string
+	}{
+		{name: "apache", body: apacheListing, listed: true, entries: []string{"backup 2024.zip", "invoices/"}},
+		{name: "nginx", body: nginxListing, listed: true, entries: []string{"dump.sql", "a&b.txt"}},
+		{name: "empty nginx directory", body: `<html><head><title>Index of /backup/</title></head><body><pre><a href="../">../</a></pre></body></html>`, listed: true},
+		{name: "site page", body: `<html><head><title>Shop</title></head><body><a href="catalog/">Catalog</a></body></html>`},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			listed, entries := parseListing([]byte(testCase.body))
+			assert.Equal(t, testCase.listed, listed)
+			assert.Equal(t, testCase.entries, entries)
+		})
+	}
+}
+
+func TestAuditListings(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, site, r.Host)
+
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`<html><head><title>Example</title></head></html>`))
+		case "/upload/":
+			w.Write([]byte(apacheListing))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+
+	task := &Task{Site: site, ListingPaths: []string{"/", "/upload/", "/backup/"}}
+	task.Connection.Addr = serverURL.Hostname()
+	task.Connection.Port = serverURL.Port()
+
+	clients := newClientSet(newTransportPool(config.Pool{}, nil, nil), task.Connection.Addr, config.Timeouts{})
+
+	require.NoError(t, auditListings(t.Context(), clients, task))
+	assert.True(t, task.Result.ListingsAudited)
+	require.Len(t, task.Result.Listings, 3)
+
+	found, ok := listingsFound(task)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"http://example.com/upload/ - backup 2024.zip, invoices/"}, found)
+
+	closed := &Task{Site: site, ListingPaths: task.ListingPaths}
+	closed.Result.Probes = []ProbeResult{{
+		Probe:      config.ProbesDefault[0],
+		StatusCode: http.StatusUnauthorized,
+		Header:     http.Header{"Www-Authenticate": []string{`Basic realm="Restricted"`}},
+	}}
+
+	require.NoError(t, auditListings(t.Context(), clients, closed))
+	assert.Empty(t, closed.Result.Listings, "directories of a closed site are not requested")
+
+	found, ok = listingsFound(closed)
+	assert.True(t, ok)
+	assert.Empty(t, found)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	assert.ErrorIs(t, auditListings(ctx, clients, &Task{Site: site, ListingPaths: task.ListingPaths}), context.Canceled)
+}
+
+func TestListingEntries(t *testing.T) {
+	assert.Equal(t, "пустой каталог", listingEntries(nil))
+	assert.Equal(t, "a, b", listingEntries([]string{"a", "b"}))
+	assert.Equal(t, "1, 2, 3, 4, 5, 6, 7, 8, 9, 10 и ещё 2", listingEntries([]string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12"}))
+}
+
+func TestResultHandlerListings(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	resultPipe := make(chan *Task)
+	wg := &sync.WaitGroup{}
+	notifierMock := notify.NewMockNotifier(gomock.NewController(t))
+
+	notifierMock.EXPECT().Finding(notify.FindingDirectoryListing, "directory_listing:client", gomock.Cond(func(message string) bool {
+		return assert.Contains(t, message, "Владелец: client\nhttp://example.com/upload/ - dump.sql")
+	})).Times(1)
+	notifierMock.EXPECT().Success(site, gomock.Any()).Times(1)
+
+	wg.Add(1)
+	go resultHandler(ctx, wg, resultPipe, notifierMock, nil, nil)
+
+	resultPipe <- &Task{
+		Site:  site,
+		Owner: "client",
+		Result: Result{
+			Probes: []ProbeResult{{Probe: config.Probe{Path: "/", Expect: config.ExpectOpen}, StatusCode: http.StatusOK}},
+			Listings: []ListingResult{{
+				URL:        "http://example.com/upload/",
+				StatusCode: http.StatusOK,
+				Listed:     true,
+				Entries:    []string{"dump.sql"},
+			}},
+			ListingsAudited: true,
+		},
+	}
+
+	cancel()
+	wg.Wait()
+}
@@ -0,0 +1,252 @@
+package checker
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+)
+
+// tcpProbe connects to the port of the site server. A probe expecting a closed port passes when the connection fails.
+type tcpProbe struct {
+	config config.Probe
+}
+
+func (p tcpProbe) Run(ctx context.Context, target Target) (ProbeResult, error) {
+	result := ProbeResult{
+		Probe: p.config,
+		URL:   net.JoinHostPort(target.Task.Connection.Addr, p.config.Port),
+	}
+
+	release, err := target.Clients.wait(ctx)
+	if err != nil {
+		return result, context.Canceled
+	}
+	defer release()
+
+	ctx, cancel := withTotalTimeout(ctx, target.Task.Timeouts)
+	defer cancel()
+
+	start := time.Now()
+
+	conn, err := target.Clients.dial(ctx, p.config.Port)
+	result.Timings.Connect = time.Since(start)
+	result.Timings.Total = result.Timings.Connect
+
+	if err != nil {
+		if isCancelled(ctx, err) {
+			return result, context.Canceled
+		}
+
+		if p.config.Expect == config.ExpectOpen {
+			result.Err = err
+		}
+
+		return result, nil
+	}
+
+	result.Connected = true
+	conn.Close()
+
+	return result, nil
+}
+
+// tlsProbe makes a handshake with the site server and verifies the certificate for the site name,
+// unlike HTTP probes which skip the verification.
+type tlsProbe struct {
+	config config.Probe
+	// roots are the trusted certificate authorities, the system ones when nil
+	roots *x509.CertPool
+}
+
+func (p tlsProbe) Run(ctx context.Context, target Target) (ProbeResult, error) {
+	host := probeHost(p.config, target.Task)
+
+	result := ProbeResult{
+		Probe: p.config,
+		URL:   net.JoinHostPort(host, p.config.Port),
+	}
+
+	release, err := target.Clients.wait(ctx)
+	if err != nil {
+		return result, context.Canceled
+	}
+	defer release()
+
+	ctx, cancel := withTotalTimeout(ctx, target.Task.Timeouts)
+	defer cancel()
+
+	start := time.Now()
+
+	conn, err := target.Clients.dial(ctx, p.config.Port)
+	result.Timings.Connect = time.Since(start)
+
+	if err != nil {
+		if isCancelled(ctx, err) {
+			return result, context.Canceled
+		}
+
+		result.Err = err
+		result.Timings.Total = result.Timings.Connect
+
+		return result, nil
+	}
+
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: host, RootCAs: p.roots})
+	defer tlsConn.Close()
+
+	handshakeCtx := ctx
+	if target.Task.Timeouts.TLSHandshake > 0 {
+		var handshakeCancel context.CancelFunc
+		handshakeCtx, handshakeCancel = context.WithTimeout(ctx, target.Task.Timeouts.TLSHandshake)
+		defer handshakeCancel()
+	}
+
+	tlsStart := time.Now()
+	err = tlsConn.HandshakeContext(handshakeCtx)
+	result.Timings.TLS = time.Since(tlsStart)
+	result.Timings.Total = time.Since(start)
+
+	if err != nil {
+		if isCancelled(ctx, err) {
+			return result, context.Canceled
+		}
+
+		result.Err = err
+	}
+
+	return result, nil
+}
+
+// dnsProbe resolves the host of the probe, the site name by default, through the task resolver.
+type dnsProbe struct {
+	config config.Probe
+}
+
+func (p dnsProbe) Run(ctx context.Context, target Target) (ProbeResult, error) {
+	host := probeHost(p.config, target.Task)
+
+	result := ProbeResult{
+		Probe: p.config,
+		URL:   host,
+	}
+
+	ctx, cancel := withTotalTimeout(ctx, target.Task.Timeouts)
+	defer cancel()
+
+	start := time.Now()
+
+	addrs, err := target.Clients.resolver(target.Task.DNSResolver).LookupNetIP(ctx, "ip", host)
+	result.Timings.Total = time.Since(start)
+
+	if err != nil {
+		if isCancelled(ctx, err) {
+			return result, context.Canceled
+		}
+
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			result.NotFound = true
+			return result, nil
+		}
+
+		result.Err = err
+
+		return result, nil
+	}
+
+	for _, addr := range addrs {
+		result.Addrs = append(result.Addrs, addr.Unmap().String())
+	}
+
+	slices.Sort(result.Addrs)
+
+	return result, nil
+}
+
+// netProbeProblem returns the problem of a TCP, TLS or DNS probe without an error.
+func netProbeProblem(task *Task, result *ProbeResult) string {
+	switch result.Probe.Type {
+	case config.ProbeTCP:
+		if result.Probe.Expect == config.ExpectClosed && result.Connected {
+			return "Порт открыт, хотя должен быть закрыт"
+		}
+	case config.ProbeDNS:
+		if result.NotFound {
+			return "Домен не найден в DNS (NXDOMAIN)"
+		}
+
+		if result.Probe.MatchServer {
+			return dnsMismatch(task.Connection.Addr, result.Addrs)
+		}
+	}
+
+	return ""
+}
+
+// dnsMismatch compares the resolved addresses of the server address family with the server address,
+// addresses of the other family are not compared as the panel gives only one address.
+func dnsMismatch(server string, addrs []string) string {
+	serverAddr, err := netip.ParseAddr(server)
+	if err != nil {
+		return fmt.Sprintf("Некорректный адрес сервера %q", server)
+	}
+
+	var (
+		found  bool
+		others []string
+	)
+
+	for _, item := range addrs {
+		addr, err := netip.ParseAddr(item)
+		if err != nil || addr.Is4() != serverAddr.Unmap().Is4() {
+			continue
+		}
+
+		if addr == serverAddr.Unmap() {
+			found = true
+			continue
+		}
+
+		others = append(others, item)
+	}
+
+	switch {
+	case !found && len(others) == 0:
+		return fmt.Sprintf("DNS не содержит адрес сервера %s, получено: %s", server, strings.Join(addrs, ", "))
+	case !found || len(others) > 0:
+		return fmt.Sprintf("DNS указывает на %s, а сайт размещён на %s", strings.Join(addrs, ", "), server)
+	}
+
+	return ""
+}
+
+func probeHost(probe config.Probe, task *Task) string {
+	if probe.Host != "" {
+		return probe.Host
+	}
+
+	return task.Site
+}
+
+func withTotalTimeout(ctx context.Context, timeouts config.Timeouts) (context.Context, context.CancelFunc) {
+	if timeouts.Total > 0 {
+		return context.WithTimeout(ctx, timeouts.Total)
+	}
+
+	return context.WithCancel(ctx)
+}
+
+// isCancelled reports that the probe was interrupted by the cancellation of the parent context,
+// not by its own timeout.
+func isCancelled(ctx context.Context, err error) bool {
+	return err != nil && errors.Is(ctx.Err(), context.Canceled)
+}
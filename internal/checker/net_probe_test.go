@@ -0,0 +1,252 @@
+package checker
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestTCPProbe(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	_, openPort, _ := net.SplitHostPort(listener.Addr().String())
+
+	closedListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	_, closedPort, _ := net.SplitHostPort(closedListener.Addr().String())
+	closedListener.Close()
+	defer listener.Close()
+
+	task := &Task{Site: site, Timeouts: config.Timeouts{Dial: time.Second, Total: time.Second}}
+	task.Connection.Addr = "127.0.0.1"
+
+	target := Target{Task: task, Clients: newClientSet(newTransportPool(config.Pool{}, nil, nil), task.Connection.Addr, task.Timeouts)}
+
+	testCases := []struct {
+		name    string
+		probe   config.Probe
+		problem bool
+	}{
+		{name: "open port expected open", probe: config.Probe{Type: config.ProbeTCP, Port: openPort, Expect: config.ExpectOpen}},
+		{name: "closed port expected open", probe: config.Probe{Type: config.ProbeTCP, Port: closedPort, Expect: config.ExpectOpen}, problem: true},
+		{name: "closed port expected closed", probe: config.Probe{Type: config.ProbeTCP, Port: closedPort, Expect: config.ExpectClosed}},
+		{name: "open port expected closed", probe: config.Probe{Type: config.ProbeTCP, Port: openPort, Expect: config.ExpectClosed}, problem: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			result, err := newProbe(testCase.probe).Run(t.Context(), target)
+			require.NoError(t, err)
+
+			assert.Equal(t, "TCP 127.0.0.1:"+testCase.probe.Port, result.Name())
+			assert.False(t, result.Closed())
+			assert.Equal(t, testCase.problem, evaluateProbe(task, &result) != "")
+		})
+	}
+}
+
+func TestTLSProbe(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	task := &Task{Site: site, Timeouts: config.Timeouts{Dial: time.Second, TLSHandshake: time.Second, Total: 2 * time.Second}}
+	task.Connection.Addr = serverURL.Hostname()
+
+	target := Target{Task: task, Clients: newClientSet(newTransportPool(config.Pool{}, nil, nil), task.Connection.Addr, task.Timeouts)}
+	probe := config.Probe{Type: config.ProbeTLS, Port: serverURL.Port(), Expect: config.ExpectOpen}
+
+	result, err := tlsProbe{config: probe, roots: roots}.Run(t.Context(), target)
+	require.NoError(t, err)
+	assert.NoError(t, result.Err)
+	assert.Equal(t, "TLS example.com:"+serverURL.Port(), result.Name())
+	assert.Positive(t, result.Timings.TLS)
+	assert.Empty(t, evaluateProbe(task, &result))
+
+	// the certificate is not issued for the name
+	probe.Host = "other.example.org"
+	result, err = tlsProbe{config: probe, roots: roots}.Run(t.Context(), target)
+	require.NoError(t, err)
+	assert.Error(t, result.Err)
+
+	// the certificate authority is unknown
+	result, err = newProbe(config.Probe{Type: config.ProbeTLS, Port: serverURL.Port(), Expect: config.ExpectOpen}).Run(t.Context(), target)
+	require.NoError(t, err)
+	assert.Error(t, result.Err)
+	assert.NotEmpty(t, evaluateProbe(task, &result))
+}
+
+func TestDNSProbe(t *testing.T) {
+	task := &Task{Site: "localhost", Timeouts: config.Timeouts{Total: 2 * time.Second}}
+	target := Target{Task: task, Clients: newClientSet(newTransportPool(config.Pool{}, nil, nil), "127.0.0.1", task.Timeouts)}
+
+	result, err := newProbe(config.Probe{Type: config.ProbeDNS, Expect: config.ExpectOpen}).Run(t.Context(), target)
+	require.NoError(t, err)
+	assert.NoError(t, result.Err)
+	assert.NotEmpty(t, result.Addrs)
+	assert.Equal(t, "DNS localhost", result.Name())
+
+}
+
+// serveDNS answers A and AAAA queries with the records over UDP and TCP on the same port, other names get NXDOMAIN.
+func serveDNS(t *testing.T, records map[string][]netip.Addr) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	listener, err := net.Listen("tcp", conn.LocalAddr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			if reply, ok := dnsReply(buf[:n], records); ok {
+				conn.WriteTo(reply, addr)
+			}
+		}
+	}()
+
+	go func() {
+		for {
+			stream, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer stream.Close()
+
+				// a TCP message is prefixed with its length
+				var length [2]byte
+				if _, err := io.ReadFull(stream, length[:]); err != nil {
+					return
+				}
+
+				buf := make([]byte, binary.BigEndian.Uint16(length[:]))
+				if _, err := io.ReadFull(stream, buf); err != nil {
+					return
+				}
+
+				if reply, ok := dnsReply(buf, records); ok {
+					stream.Write(binary.BigEndian.AppendUint16(nil, uint16(len(reply))))
+					stream.Write(reply)
+				}
+			}()
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func dnsReply(buf []byte, records map[string][]netip.Addr) ([]byte, bool) {
+	var query dnsmessage.Message
+	if err := query.Unpack(buf); err != nil || len(query.Questions) != 1 {
+		return nil, false
+	}
+
+	question := query.Questions[0]
+	addrs, ok := records[question.Name.String()]
+
+	reply := dnsmessage.Message{
+		Header: dnsmessage.Header{
+			ID:                 query.ID,
+			Response:           true,
+			Authoritative:      true,
+			RecursionAvailable: true,
+		},
+		Questions: query.Questions,
+	}
+
+	if !ok {
+		reply.RCode = dnsmessage.RCodeNameError
+	}
+
+	for _, item := range addrs {
+		header := dnsmessage.ResourceHeader{Name: question.Name, Class: dnsmessage.ClassINET, TTL: 60}
+
+		switch {
+		case item.Is4() && question.Type == dnsmessage.TypeA:
+			reply.Answers = append(reply.Answers, dnsmessage.Resource{Header: header, Body: &dnsmessage.AResource{A: item.As4()}})
+		case item.Is6() && question.Type == dnsmessage.TypeAAAA:
+			reply.Answers = append(reply.Answers, dnsmessage.Resource{Header: header, Body: &dnsmessage.AAAAResource{AAAA: item.As16()}})
+		}
+	}
+
+	packed, err := reply.Pack()
+
+	return packed, err == nil
+}
+
+func TestDNSProbeMatchServer(t *testing.T) {
+	resolver := serveDNS(t, map[string][]netip.Addr{
+		"example.com.":       {netip.MustParseAddr("203.0.113.10"), netip.MustParseAddr("2001:db8::10")},
+		"moved.example.com.": {netip.MustParseAddr("198.51.100.7")},
+		"both.example.com.":  {netip.MustParseAddr("203.0.113.10"), netip.MustParseAddr("198.51.100.7")},
+		"v6.example.com.":    {netip.MustParseAddr("2001:db8::10")},
+	})
+
+	testCases := []struct {
+		site     string
+		problem  string
+		notFound bool
+	}{
+		{site: "example.com"},
+		{site: "moved.example.com", problem: "DNS указывает на 198.51.100.7, а сайт размещён на 203.0.113.10"},
+		{site: "both.example.com", problem: "DNS указывает на 198.51.100.7, 203.0.113.10, а сайт размещён на 203.0.113.10"},
+		{site: "v6.example.com", problem: "DNS не содержит адрес сервера 203.0.113.10, получено: 2001:db8::10"},
+		{site: "gone.example.com", problem: "Домен не найден в DNS (NXDOMAIN)", notFound: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.site, func(t *testing.T) {
+			task := &Task{Site: testCase.site, DNSResolver: resolver, Timeouts: config.Timeouts{Total: 2 * time.Second}}
+			task.Connection.Addr = "203.0.113.10"
+
+			target := Target{Task: task, Clients: newClientSet(newTransportPool(config.Pool{}, nil, nil), task.Connection.Addr, task.Timeouts)}
+
+			result, err := newProbe(config.Probe{Type: config.ProbeDNS, MatchServer: true, Expect: config.ExpectOpen}).Run(t.Context(), target)
+			require.NoError(t, err)
+			require.NoError(t, result.Err)
+
+			assert.Equal(t, testCase.notFound, result.NotFound)
+			assert.Equal(t, testCase.problem, evaluateProbe(task, &result))
+		})
+	}
+}
+
+func TestNetProbeCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	task := &Task{Site: site}
+	task.Connection.Addr = "127.0.0.1"
+	target := Target{Task: task, Clients: newClientSet(newTransportPool(config.Pool{}, nil, nil), task.Connection.Addr, task.Timeouts)}
+
+	_, err := newProbe(config.Probe{Type: config.ProbeTCP, Port: "1", Expect: config.ExpectClosed}).Run(ctx, target)
+	assert.ErrorIs(t, err, context.Canceled)
+}
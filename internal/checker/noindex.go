@@ -0,0 +1,259 @@
+package checker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+)
+
+const (
+	noindexBodyLimit = 256 * 1024
+	robotsBodyLimit  = 64 * 1024
+)
+
+var (
+	metaTag       = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	metaAttribute = regexp.MustCompile(`(?i)([a-z-]+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	// robotsMetaNames are the meta tag names read by the search engines
+	robotsMetaNames = []string{"robots", "googlebot", "yandex", "bingbot"}
+)
+
+// NoIndexResult is the search engine protection of a site. The page and robots.txt of a closed site are read
+// with the site credentials when they are known.
+type NoIndexResult struct {
+	URL string
+	// Header is set when X-Robots-Tag of the page response forbids indexing
+	Header bool
+	// Meta is set when the robots meta tag of the page forbids indexing, it's checked only for an HTML page
+	Meta        bool
+	MetaChecked bool
+	// Robots is set when robots.txt disallows everything for all user agents
+	Robots       bool
+	RobotsStatus int
+	Err          error
+}
+
+// problems describes the missing protection.
+func (r *NoIndexResult) problems() []string {
+	var problems []string
+
+	if !r.Header {
+		problems = append(problems, "нет заголовка X-Robots-Tag: noindex")
+	}
+
+	if r.MetaChecked && !r.Meta {
+		problems = append(problems, "нет мета-тега robots с noindex")
+	}
+
+	switch {
+	case r.RobotsStatus < 200 || r.RobotsStatus >= 300:
+		problems = append(problems, fmt.Sprintf("robots.txt недоступен, код ответа %d", r.RobotsStatus))
+	case !r.Robots:
+		problems = append(problems, "robots.txt не запрещает индексацию (нужно User-agent: * и Disallow: /)")
+	}
+
+	return problems
+}
+
+// auditNoIndex checks the search engine protection of a non-production site. A closed site is checked too,
+// the auth may be removed later.
+func auditNoIndex(ctx context.Context, clients *clientSet, task *Task) error {
+	result := &NoIndexResult{URL: fmt.Sprintf("%s://%s/", schemeForPort(task.Connection.Port), task.Site)}
+	task.Result.NoIndex = result
+
+	page, err := fetchContent(ctx, clients, task, "/", noindexBodyLimit)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		result.Err = err
+		return nil
+	}
+
+	result.Header = robotsNoIndex(page.Header.Values("X-Robots-Tag"))
+
+	if page.StatusCode >= 200 && page.StatusCode < 300 && isHTML(page.Header) {
+		result.MetaChecked = true
+		result.Meta = metaNoIndex(page.Body)
+	}
+
+	robots, err := fetchContent(ctx, clients, task, "/robots.txt", robotsBodyLimit)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		result.Err = err
+		return nil
+	}
+
+	result.RobotsStatus = robots.StatusCode
+	result.Robots = robots.StatusCode >= 200 && robots.StatusCode < 300 && robotsDisallowAll(robots.Body)
+
+	return nil
+}
+
+// fetchContent gets the path following the redirects, a closed page is requested again with the site credentials
+// when they are known.
+func fetchContent(ctx context.Context, clients *clientSet, task *Task, path string, bodyLimit int64) (*ProbeResult, error) {
+	result := &ProbeResult{
+		Probe: config.Probe{Path: path, Method: http.MethodGet, Headers: task.Headers},
+		URL:   fmt.Sprintf("%s://%s%s", schemeForPort(task.Connection.Port), task.Site, path),
+	}
+
+	if err := fetch(ctx, clients, task, result, bodyLimit); err != nil {
+		return result, err
+	}
+
+	if result.StatusCode != http.StatusUnauthorized || task.Credentials == nil {
+		return result, nil
+	}
+
+	challenge, err := verifyAuthChallenge(result.Header, "")
+	if err != nil {
+		return result, nil
+	}
+
+	finalURL, err := url.Parse(result.FinalURL())
+	if err != nil {
+		return result, err
+	}
+
+	authorization, err := authorizationHeader(challenge, task.Credentials, http.MethodGet, finalURL.RequestURI())
+	if err != nil {
+		return result, nil
+	}
+
+	headers := maps.Clone(task.Headers)
+	if headers == nil {
+		headers = make(map[string]string)
+	}
+
+	headers["Authorization"] = authorization
+
+	authorized := &ProbeResult{
+		Probe: config.Probe{Path: path, Method: http.MethodGet, Headers: headers},
+		URL:   finalURL.String(),
+	}
+
+	return authorized, fetch(ctx, clients, task, authorized, bodyLimit)
+}
+
+// robotsNoIndex reports whether the X-Robots-Tag values or the robots meta content forbid indexing. A directive
+// may be prefixed with the user agent, like "googlebot: noindex".
+func robotsNoIndex(values []string) bool {
+	for _, value := range values {
+		for _, directive := range strings.Split(value, ",") {
+			directive = strings.TrimSpace(directive)
+
+			if agent, rule, ok := strings.Cut(directive, ":"); ok && !strings.Contains(strings.TrimSpace(agent), " ") {
+				directive = strings.TrimSpace(rule)
+			}
+
+			if strings.EqualFold(directive, "noindex") || strings.EqualFold(directive, "none") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func metaNoIndex(body []byte) bool {
+	for _, tag := range metaTag.FindAll(body, -1) {
+		attributes := make(map[string]string)
+
+		for _, match := range metaAttribute.FindAllSubmatch(tag, -1) {
+			attributes[strings.ToLower(string(match[1]))] = string(match[2]) + string(match[3]) + string(match[4])
+		}
+
+		if slices.Contains(robotsMetaNames, strings.ToLower(attributes["name"])) && robotsNoIndex([]string{attributes["content"]}) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// robotsDisallowAll reports whether robots.txt disallows every path for all user agents.
+func robotsDisallowAll(body []byte) bool {
+	var (
+		all      bool
+		inRules  bool
+		disallow bool
+		allow    bool
+	)
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// a user agent after the rules starts a new group
+			if inRules {
+				all, inRules = false, false
+			}
+
+			if value == "*" {
+				all = true
+			}
+		case "disallow", "allow":
+			inRules = true
+
+			if !all {
+				continue
+			}
+
+			if key == "disallow" && (value == "/" || value == "/*") {
+				disallow = true
+			}
+
+			if key == "allow" && value != "" {
+				allow = true
+			}
+		}
+	}
+
+	return disallow && !allow
+}
+
+func isHTML(header http.Header) bool {
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+
+	return err == nil && mediaType == "text/html"
+}
+
+// noindexFound returns the missing protection of the audited task, false when the audit was not done or failed.
+func noindexFound(task *Task) ([]string, bool) {
+	result := task.Result.NoIndex
+	if result == nil || result.Err != nil {
+		return nil, false
+	}
+
+	problems := result.problems()
+	if len(problems) == 0 {
+		return nil, true
+	}
+
+	return []string{fmt.Sprintf("%s - %s", result.URL, strings.Join(problems, "; "))}, true
+}
@@ -0,0 +1,177 @@
+package checker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRobotsNoIndex(t *testing.T) {
+	testCases := []struct {
+		values   []string
+		expected bool
+	}{
+		{values: []string{"noindex"}, expected: true},
+		{values: []string{"noindex, nofollow"}, expected: true},
+		{values: []string{"NONE"}, expected: true},
+		{values: []string{"nofollow", "googlebot: noindex"}, expected: true},
+		{values: []string{"unavailable_after: 25 Jun 2010 15:00:00 PST"}},
+		{values: []string{"nofollow, noarchive"}},
+		{values: []string{"index, follow"}},
+		{},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.expected, robotsNoIndex(testCase.values), testCase.values)
+	}
+}
+
+func TestMetaNoIndex(t *testing.T) {
+	testCases := []struct {
+		body     string
+		expected bool
+	}{
+		{body: `<head><meta name="robots" content="noindex, nofollow"></head>`, expected: true},
+		{body: `<head><META CONTENT='noindex' NAME='Robots' /></head>`, expected: true},
+		{body: "<head><meta\n  name=googlebot\n  content=none></head>", expected: true},
+		{body: `<head><meta name="robots" content="index, follow"></head>`},
+		{body: `<head><meta name="description" content="noindex is not here"></head>`},
+		{body: `<head><title>noindex</title></head>`},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.expected, metaNoIndex([]byte(testCase.body)), testCase.body)
+	}
+}
+
+func TestRobotsDisallowAll(t *testing.T) {
+	testCases := []struct {
+		name     string
+		body     string
+		expected bool
+	}{
+		{name: "disallow all", body: "User-agent: *\nDisallow: /\n", expected: true},
+		{name: "with comments and other groups", body: "# staging\nUser-agent: Yandex\nDisallow: /admin/\n\nuser-agent: *  # everyone\ndisallow: /*\n", expected: true},
+		{name: "shared group", body: "User-agent: Googlebot\nUser-agent: *\nDisallow: /\n", expected: true},
+		{name: "empty disallow", body: "User-agent: *\nDisallow:\n"},
+		{name: "only for one bot", body: "User-agent: Googlebot\nDisallow: /\n\nUser-agent: *\nDisallow: /admin/\n"},
+		{name: "allowed path", body: "User-agent: *\nDisallow: /\nAllow: /public/\n"},
+		{name: "site page", body: "<html><body>Not found</body></html>"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, robotsDisallowAll([]byte(testCase.body)))
+		})
+	}
+}
+
+func TestAuditNoIndex(t *testing.T) {
+	credentials := &config.Credentials{Site: site, Username: "dev", Password: "secret"}
+
+	// a staging site behind auth, robots.txt is served without it
+	protected := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+
+		if r.URL.Path == "/robots.txt" {
+			w.Write([]byte("User-agent: *\nDisallow: /\n"))
+			return
+		}
+
+		if username, password, ok := r.BasicAuth(); !ok || username != "dev" || password != "secret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Staging"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<html><head><meta name="robots" content="noindex"></head></html>`))
+	}))
+	defer protected.Close()
+
+	// an open staging site without any protection, the root redirects to the page
+	open := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			http.Redirect(w, r, "/ru/", http.StatusFound)
+		case "/ru/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><head><title>Shop</title></head></html>`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer open.Close()
+
+	testCases := []struct {
+		name        string
+		server      *httptest.Server
+		credentials *config.Credentials
+		expected    NoIndexResult
+		problems    []string
+	}{
+		{
+			name:        "closed site read with credentials",
+			server:      protected,
+			credentials: credentials,
+			expected:    NoIndexResult{Header: true, Meta: true, MetaChecked: true, Robots: true, RobotsStatus: http.StatusOK},
+		},
+		{
+			name:     "closed site without credentials",
+			server:   protected,
+			expected: NoIndexResult{Header: true, Robots: true, RobotsStatus: http.StatusOK},
+		},
+		{
+			name:     "open site",
+			server:   open,
+			expected: NoIndexResult{MetaChecked: true, RobotsStatus: http.StatusNotFound},
+			problems: []string{"нет заголовка X-Robots-Tag: noindex", "нет мета-тега robots с noindex", "robots.txt недоступен, код ответа 404"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			serverURL, _ := url.Parse(testCase.server.URL)
+
+			task := &Task{Site: site, Credentials: testCase.credentials, NoIndex: true}
+			task.Connection.Addr = serverURL.Hostname()
+			task.Connection.Port = serverURL.Port()
+			task.Redirects = config.RedirectPolicy{Mode: config.RedirectFollow, Max: 10}
+
+			clients := newClientSet(newTransportPool(config.Pool{}, nil, nil), task.Connection.Addr, config.Timeouts{})
+
+			require.NoError(t, auditNoIndex(t.Context(), clients, task))
+			require.NotNil(t, task.Result.NoIndex)
+
+			testCase.expected.URL = "http://example.com/"
+			assert.Equal(t, testCase.expected, *task.Result.NoIndex)
+			assert.Equal(t, testCase.problems, task.Result.NoIndex.problems())
+
+			found, ok := noindexFound(task)
+			assert.True(t, ok)
+			assert.Equal(t, len(testCase.problems) > 0, len(found) == 1)
+		})
+	}
+}
+
+func TestNoIndexFound(t *testing.T) {
+	_, ok := noindexFound(&Task{})
+	assert.False(t, ok, "the audit is not done")
+
+	task := &Task{}
+	task.Result.NoIndex = &NoIndexResult{URL: "http://a.ru/", Err: http.ErrHandlerTimeout}
+
+	_, ok = noindexFound(task)
+	assert.False(t, ok, "a failed audit keeps the previous findings")
+
+	task.Result.NoIndex = &NoIndexResult{URL: "http://a.ru/", Header: true, RobotsStatus: http.StatusOK}
+
+	found, ok := noindexFound(task)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"http://a.ru/ - robots.txt не запрещает индексацию (нужно User-agent: * и Disallow: /)"}, found)
+}
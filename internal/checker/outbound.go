@@ -0,0 +1,208 @@
+package checker
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+	"golang.org/x/net/proxy"
+)
+
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// outbound sends the connections of the checks from the source address, directly or through the proxy,
+// so the checks see the sites as an external visitor. A nil outbound dials directly from any address.
+type outbound struct {
+	source *net.TCPAddr
+	proxy  *url.URL
+}
+
+func newOutbound(cfg config.Outbound) *outbound {
+	if cfg.SourceAddr == "" && cfg.Proxy == "" {
+		return nil
+	}
+
+	result := &outbound{}
+
+	if cfg.SourceAddr != "" {
+		result.source = &net.TCPAddr{IP: net.ParseIP(cfg.SourceAddr)}
+	}
+
+	if cfg.Proxy != "" {
+		// the address is validated by the config
+		result.proxy, _ = url.Parse(cfg.Proxy)
+	}
+
+	return result
+}
+
+// localDialer dials from the source address without the proxy.
+func (o *outbound) localDialer(timeouts config.Timeouts) *net.Dialer {
+	dialer := &net.Dialer{
+		Timeout:   timeouts.Dial,
+		KeepAlive: timeouts.KeepAlive,
+	}
+
+	if o != nil && o.source != nil {
+		dialer.LocalAddr = o.source
+	}
+
+	return dialer
+}
+
+// proxied reports that the checks go through the proxy.
+func (o *outbound) proxied() bool {
+	return o != nil && o.proxy != nil
+}
+
+// dialer returns the dial function of the checks, through the proxy when it's set.
+func (o *outbound) dialer(timeouts config.Timeouts) dialFunc {
+	local := o.localDialer(timeouts)
+
+	if o == nil || o.proxy == nil {
+		return local.DialContext
+	}
+
+	if o.proxy.Scheme == "http" {
+		proxyURL := o.proxy
+
+		return func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dialConnect(ctx, local, proxyURL, addr)
+		}
+	}
+
+	socks, err := proxy.FromURL(o.proxy, local)
+	if err != nil {
+		return func(context.Context, string, string) (net.Conn, error) {
+			return nil, fmt.Errorf("proxy %s: %w", o.proxy.Redacted(), err)
+		}
+	}
+
+	if contextDialer, ok := socks.(proxy.ContextDialer); ok {
+		return contextDialer.DialContext
+	}
+
+	return func(_ context.Context, network, addr string) (net.Conn, error) {
+		return socks.Dial(network, addr)
+	}
+}
+
+// resolver returns the resolver of the DNS probes sending queries to addr, to the servers of the system config
+// when addr is empty. Through the proxy the queries go over TCP, the proxies don't relay UDP.
+func (o *outbound) resolver(addr string, timeouts config.Timeouts) *net.Resolver {
+	if o == nil && addr == "" {
+		return net.DefaultResolver
+	}
+
+	dial := o.dialer(timeouts)
+	local := o.localDialer(timeouts)
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, server string) (net.Conn, error) {
+			if addr != "" {
+				server = addr
+			}
+
+			if o.proxied() {
+				return dial(ctx, "tcp", server)
+			}
+
+			if o != nil && o.source != nil && strings.HasPrefix(network, "udp") {
+				udp := *local
+				udp.LocalAddr = &net.UDPAddr{IP: o.source.IP}
+
+				return udp.DialContext(ctx, network, server)
+			}
+
+			return local.DialContext(ctx, network, server)
+		},
+	}
+}
+
+// dialConnect opens a tunnel to addr with the HTTP CONNECT method.
+func dialConnect(ctx context.Context, dialer *net.Dialer, proxyURL *url.URL, addr string) (net.Conn, error) {
+	conn, err := dialer.DialContext(ctx, "tcp", proxyURL.Host)
+	if err != nil {
+		return nil, fmt.Errorf("proxy %s: %w", proxyURL.Redacted(), err)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Unix(1, 0))
+	})
+
+	tunnel, err := connect(conn, proxyURL, addr)
+
+	if !stop() || err != nil {
+		conn.Close()
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		return nil, fmt.Errorf("proxy %s: %w", proxyURL.Redacted(), err)
+	}
+
+	conn.SetDeadline(time.Time{})
+
+	return tunnel, nil
+}
+
+func connect(conn net.Conn, proxyURL *url.URL, addr string) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+
+	if user := proxyURL.User; user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("CONNECT " + addr + ": " + resp.Status)
+	}
+
+	if reader.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+
+	return conn, nil
+}
+
+// bufferedConn returns the bytes read ahead with the CONNECT response before reading the connection.
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
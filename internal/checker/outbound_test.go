@@ -0,0 +1,232 @@
+package checker
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// connectProxy is an HTTP CONNECT proxy which records the tunnel targets.
+type connectProxy struct {
+	mu      sync.Mutex
+	targets []string
+}
+
+func (p *connectProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Proxy-Authorization") != "Basic dXNlcjpzZWNyZXQ=" {
+		w.WriteHeader(http.StatusProxyAuthRequired)
+		return
+	}
+
+	p.mu.Lock()
+	p.targets = append(p.targets, r.Host)
+	p.mu.Unlock()
+
+	target, err := net.Dial("tcp", r.Host)
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		target.Close()
+		return
+	}
+
+	conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+
+	pipe(conn, target)
+}
+
+// serveSOCKS5 accepts SOCKS5 connections without authentication and records the targets.
+func serveSOCKS5(t *testing.T, targets chan<- string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				buf := make([]byte, 262)
+
+				// greeting: version, methods count, methods
+				if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+					conn.Close()
+					return
+				}
+				io.ReadFull(conn, buf[:buf[1]])
+				conn.Write([]byte{5, 0})
+
+				// request: version, connect, reserved, IPv4 address and port
+				if _, err := io.ReadFull(conn, buf[:10]); err != nil || buf[3] != 1 {
+					conn.Close()
+					return
+				}
+
+				addr := net.JoinHostPort(net.IP(buf[4:8]).String(), strconv.Itoa(int(binary.BigEndian.Uint16(buf[8:10]))))
+				targets <- addr
+
+				target, err := net.Dial("tcp", addr)
+				if err != nil {
+					conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+					conn.Close()
+					return
+				}
+
+				conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+				pipe(conn, target)
+			}()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func pipe(a, b net.Conn) {
+	go func() {
+		io.Copy(a, b)
+		a.Close()
+	}()
+
+	io.Copy(b, a)
+	b.Close()
+}
+
+func TestOutboundProxy(t *testing.T) {
+	var remoteAddrs []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remoteAddrs = append(remoteAddrs, r.RemoteAddr)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+
+	connect := &connectProxy{}
+	proxyServer := httptest.NewServer(connect)
+	defer proxyServer.Close()
+
+	proxyURL, _ := url.Parse(proxyServer.URL)
+
+	socksTargets := make(chan string, 10)
+	socksAddr := serveSOCKS5(t, socksTargets)
+
+	task := &Task{Site: site}
+	task.Connection.Addr = serverURL.Hostname()
+	task.Connection.Port = serverURL.Port()
+
+	probe := config.Probe{Path: "/", Method: http.MethodGet, Expect: config.ExpectOpen}
+
+	run := func(outbound config.Outbound) ProbeResult {
+		pool := newTransportPool(config.Pool{}, nil, newOutbound(outbound))
+
+		result, err := runProbe(t.Context(), newClientSet(pool, task.Connection.Addr, config.Timeouts{}), task, probe)
+		require.NoError(t, err)
+
+		return result
+	}
+
+	result := run(config.Outbound{SourceAddr: "127.0.0.1", Proxy: "http://user:secret@" + proxyURL.Host})
+	require.NoError(t, result.Err)
+	assert.Equal(t, http.StatusNoContent, result.StatusCode)
+	assert.Equal(t, []string{serverURL.Host}, connect.targets)
+
+	result = run(config.Outbound{Proxy: "http://user:wrong@" + proxyURL.Host})
+	assert.ErrorContains(t, result.Err, "407")
+
+	result = run(config.Outbound{Proxy: "socks5://" + socksAddr})
+	require.NoError(t, result.Err)
+	assert.Equal(t, http.StatusNoContent, result.StatusCode)
+	assert.Equal(t, serverURL.Host, <-socksTargets)
+
+	// the TCP probe goes through the proxy too
+	pool := newTransportPool(config.Pool{}, nil, newOutbound(config.Outbound{Proxy: "socks5://" + socksAddr}))
+	target := Target{Task: task, Clients: newClientSet(pool, task.Connection.Addr, config.Timeouts{})}
+
+	result, err := newProbe(config.Probe{Type: config.ProbeTCP, Port: serverURL.Port(), Expect: config.ExpectOpen}).Run(t.Context(), target)
+	require.NoError(t, err)
+	assert.True(t, result.Connected)
+	assert.Equal(t, serverURL.Host, <-socksTargets)
+
+	result = run(config.Outbound{SourceAddr: "127.0.0.1"})
+	require.NoError(t, result.Err)
+	host, _, _ := net.SplitHostPort(remoteAddrs[len(remoteAddrs)-1])
+	assert.Equal(t, "127.0.0.1", host)
+}
+
+func TestOutboundResolver(t *testing.T) {
+	resolverAddr := serveDNS(t, map[string][]netip.Addr{"example.com.": {netip.MustParseAddr("203.0.113.10")}})
+
+	connect := &connectProxy{}
+	proxyServer := httptest.NewServer(connect)
+	defer proxyServer.Close()
+
+	proxyURL, _ := url.Parse(proxyServer.URL)
+
+	socksTargets := make(chan string, 10)
+	socksAddr := serveSOCKS5(t, socksTargets)
+
+	lookup := func(outbound config.Outbound) []netip.Addr {
+		addrs, err := newOutbound(outbound).resolver(resolverAddr, config.Timeouts{}).LookupNetIP(t.Context(), "ip4", "example.com")
+		require.NoError(t, err)
+
+		return addrs
+	}
+
+	expected := []netip.Addr{netip.MustParseAddr("203.0.113.10")}
+
+	assert.Equal(t, expected, lookup(config.Outbound{SourceAddr: "127.0.0.1"}), "UDP queries are sent from the source address")
+
+	assert.Equal(t, expected, lookup(config.Outbound{Proxy: "http://user:secret@" + proxyURL.Host}))
+	assert.Equal(t, []string{resolverAddr}, connect.targets)
+
+	assert.Equal(t, expected, lookup(config.Outbound{Proxy: "socks5://" + socksAddr}))
+	assert.Equal(t, resolverAddr, <-socksTargets)
+
+	assert.Same(t, net.DefaultResolver, (*outbound)(nil).resolver("", config.Timeouts{}))
+}
+
+func TestOutboundPublicPath(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+
+	connect := &connectProxy{}
+	proxyServer := httptest.NewServer(connect)
+	defer proxyServer.Close()
+
+	proxyURL, _ := url.Parse(proxyServer.URL)
+
+	transport := newPublicTransport(config.Timeouts{}, newOutbound(config.Outbound{Proxy: "http://user:secret@" + proxyURL.Host}))
+	assert.Nil(t, transport.Proxy, "the outbound proxy replaces the system one")
+
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, []string{serverURL.Host}, connect.targets)
+
+	assert.NotNil(t, newPublicTransport(config.Timeouts{}, nil).Proxy, "without the outbound proxy the system one is used")
+}
@@ -0,0 +1,59 @@
+package checker
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+)
+
+const phpErrorSnippetSize = 200
+
+var phpErrorSignaturesDefault = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)(?:<b>)?(?:PHP )?Fatal error(?:</b>)?:\s`),
+	regexp.MustCompile(`(?i)(?:<b>)?(?:PHP )?Parse error(?:</b>)?:\s`),
+	regexp.MustCompile(`(?i)(?:<b>)?(?:PHP )?Warning(?:</b>)?:\s[^\n]{0,500}? on line`),
+	regexp.MustCompile(`(?i)Error establishing a database connection`),
+	regexp.MustCompile(`(?i)Error connecting to database`),
+	regexp.MustCompile(`(?i)DB query error\.`),
+	regexp.MustCompile(`(?i)MySQL Query Error`),
+}
+
+var (
+	htmlTagRe    = regexp.MustCompile(`<[^>]*>`)
+	whitespaceRe = regexp.MustCompile(`\s+`)
+)
+
+func phpErrorSignatures(cfg *config.Config) []*regexp.Regexp {
+	return append(phpErrorSignaturesDefault[:len(phpErrorSignaturesDefault):len(phpErrorSignaturesDefault)], cfg.PHPSignatures...)
+}
+
+// detectPHPError returns the text around the first matched signature, or an empty string if the page looks fine.
+func detectPHPError(signatures []*regexp.Regexp, body []byte) string {
+	for _, re := range signatures {
+		loc := re.FindIndex(body)
+		if loc == nil {
+			continue
+		}
+
+		end := loc[0] + phpErrorSnippetSize
+		if lineEnd := bytes.IndexByte(body[loc[0]:], '\n'); lineEnd >= 0 && loc[0]+lineEnd < end {
+			end = loc[0] + lineEnd
+		}
+
+		if end < loc[1] {
+			end = loc[1]
+		}
+
+		if end > len(body) {
+			end = len(body)
+		}
+
+		text := htmlTagRe.ReplaceAllString(string(body[loc[0]:end]), "")
+
+		return strings.TrimSpace(whitespaceRe.ReplaceAllString(text, " "))
+	}
+
+	return ""
+}
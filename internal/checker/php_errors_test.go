@@ -0,0 +1,62 @@
+package checker
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectPHPError(t *testing.T) {
+	testCases := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{
+			name:     "fatal error",
+			body:     "<html>\n<br />\n<b>Fatal error</b>:  Uncaught Error: Call to undefined function mysql_connect() in /var/www/site/index.php:12\nStack trace:",
+			expected: "Fatal error: Uncaught Error: Call to undefined function mysql_connect() in /var/www/site/index.php:12",
+		},
+		{
+			name:     "parse error",
+			body:     "Parse error: syntax error, unexpected '}' in /var/www/site/index.php on line 5",
+			expected: "Parse error: syntax error, unexpected '}' in /var/www/site/index.php on line 5",
+		},
+		{
+			name:     "warning",
+			body:     "<b>Warning</b>:  include(config.php): Failed to open stream in <b>/var/www/site/index.php</b> on line <b>3</b><br />",
+			expected: "Warning: include(config.php): Failed to open stream in /var/www/site/index.php on line 3",
+		},
+		{
+			name:     "wordpress database error",
+			body:     "<body id=\"error-page\"><h1>Error establishing a database connection</h1></body>",
+			expected: "Error establishing a database connection",
+		},
+		{
+			name: "warning without line is not an error",
+			body: "<p>Warning: this product contains nuts</p>",
+		},
+		{
+			name: "normal page",
+			body: "<html><title>Shop</title></html>",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, detectPHPError(phpErrorSignaturesDefault, []byte(testCase.body)))
+		})
+	}
+}
+
+func TestPHPErrorSignaturesWithCustom(t *testing.T) {
+	custom := regexp.MustCompile("Сайт временно недоступен")
+
+	signatures := phpErrorSignatures(&config.Config{PHPSignatures: []*regexp.Regexp{custom}})
+
+	assert.Len(t, signatures, len(phpErrorSignaturesDefault)+1)
+	assert.Equal(t, "Сайт временно недоступен", detectPHPError(signatures, []byte("<h1>Сайт временно недоступен</h1>")))
+	assert.Len(t, phpErrorSignatures(&config.Config{}), len(phpErrorSignaturesDefault))
+}
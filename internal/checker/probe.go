@@ -0,0 +1,263 @@
+package checker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+)
+
+// Probe checks one aspect of the target. The error is returned only when the check was interrupted by the context,
+// problems found by the probe are in the result.
+type Probe interface {
+	Run(ctx context.Context, target Target) (ProbeResult, error)
+}
+
+// Target is the site checked by the probes with the clients of its server.
+type Target struct {
+	Task    *Task
+	Clients *clientSet
+}
+
+// newProbe returns the probe implementation for the probe type.
+func newProbe(probe config.Probe) Probe {
+	switch probe.Type {
+	case config.ProbeTCP:
+		return tcpProbe{config: probe}
+	case config.ProbeTLS:
+		return tlsProbe{config: probe}
+	case config.ProbeDNS:
+		return dnsProbe{config: probe}
+	default:
+		return httpProbe{config: probe}
+	}
+}
+
+type httpProbe struct {
+	config config.Probe
+}
+
+func (p httpProbe) Run(ctx context.Context, target Target) (ProbeResult, error) {
+	return runProbe(ctx, target.Clients, target.Task, p.config)
+}
+
+type ProbeResult struct {
+	Probe      config.Probe
+	URL        string
+	StatusCode int
+	Header     http.Header
+	// Body is read only for probes with body assertions or error detection and is cut at the task body limit
+	Body          []byte
+	BodyTruncated bool
+	Timings       Timings
+	Err           error
+	// Attempts is the number of sent probes, more than one when the probe was retried after connection errors
+	Attempts int
+	// Authenticated is the result of the request with site credentials, nil if it was not sent
+	Authenticated *AuthResult
+	// Redirects is the chain of redirects received before the final response
+	Redirects    []Redirect
+	RedirectLoop bool
+	// Connected is set by TCP probes when the port accepted the connection
+	Connected bool
+	// Addrs are the addresses resolved by DNS probes, NotFound is set when the name doesn't exist
+	Addrs    []string
+	NotFound bool
+	// Public is the response through the public path, set only for closed probes when the check is enabled
+	Public *PublicResult
+}
+
+// Closed reports that the probe expected a closed site and got 401.
+func (r *ProbeResult) Closed() bool {
+	return r.Probe.IsHTTP() && r.Probe.Expect == config.ExpectClosed && r.Err == nil && r.StatusCode == http.StatusUnauthorized
+}
+
+func (r *ProbeResult) Name() string {
+	if !r.Probe.IsHTTP() {
+		return fmt.Sprintf("%s %s", strings.ToUpper(r.Probe.Type), r.URL)
+	}
+
+	return fmt.Sprintf("%s %s", r.Probe.Method, r.URL)
+}
+
+// FinalURL returns the address of the last received response.
+func (r *ProbeResult) FinalURL() string {
+	if len(r.Redirects) == 0 {
+		return r.URL
+	}
+
+	return r.Redirects[len(r.Redirects)-1].Location
+}
+
+func runProbe(ctx context.Context, clients *clientSet, task *Task, probe config.Probe) (ProbeResult, error) {
+	probe.Headers = probeHeaders(task, probe)
+
+	result := ProbeResult{
+		Probe: probe,
+		URL:   fmt.Sprintf("%s://%s%s", schemeForPort(task.Connection.Port), task.Site, probe.Path),
+	}
+
+	var bodyLimit int64
+	if probe.HasBodyAssertions() || (probe.Expect == config.ExpectOpen && len(task.ErrorSignatures) > 0) {
+		bodyLimit = task.MaxBodySize
+	}
+
+	if err := fetch(ctx, clients, task, &result, bodyLimit); err != nil {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+
+		result.Err = err
+
+		return result, nil
+	}
+
+	if result.Closed() && task.Credentials != nil {
+		finalURL, err := url.Parse(result.FinalURL())
+		if err != nil {
+			return result, err
+		}
+
+		if err := checkCredentials(ctx, clients.verified(task.dialPort(finalURL)), task.Credentials, &result); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// evaluateProbe returns the description of the probe problem, or an empty string when the probe passed.
+func evaluateProbe(task *Task, result *ProbeResult) string {
+	if result.Err != nil {
+		if result.Attempts > 1 {
+			return fmt.Sprintf("Произошла ошибка: %s (попыток: %d)", result.Err.Error(), result.Attempts)
+		}
+
+		return fmt.Sprintf("Произошла ошибка: %s", result.Err.Error())
+	}
+
+	if !result.Probe.IsHTTP() {
+		return netProbeProblem(task, result)
+	}
+
+	if problem := redirectProblem(task, result); problem != "" {
+		return problem
+	}
+
+	if result.Probe.Expect == config.ExpectOpen {
+		if !statusExpected(result.Probe.Status, result.StatusCode) {
+			return fmt.Sprintf("Код ответа: %d", result.StatusCode)
+		}
+
+		if text := detectPHPError(task.ErrorSignatures, result.Body); text != "" {
+			return fmt.Sprintf("Страница с ошибкой: %q (PHP %s, обработчик %s)", text, task.PHPVersion, task.PHPHandler)
+		}
+
+		return bodyProblem(result)
+	}
+
+	if result.StatusCode != http.StatusUnauthorized {
+		return fmt.Sprintf("Код ответа: %d", result.StatusCode)
+	}
+
+	if _, err := verifyAuthChallenge(result.Header, task.AuthRealm); err != nil {
+		return fmt.Sprintf("Код ответа 401 без запроса авторизации: %s", err)
+	}
+
+	if result.Authenticated != nil && result.Authenticated.Broken() {
+		return fmt.Sprintf("Сайт закрыт, но не работает: %s", authResultText(result.Authenticated))
+	}
+
+	return bodyProblem(result)
+}
+
+func bodyProblem(result *ProbeResult) string {
+	if !result.Probe.HasBodyAssertions() {
+		return ""
+	}
+
+	if problem := checkBody(result.Probe, result.Body, result.BodyTruncated); problem != "" {
+		return fmt.Sprintf("Проверка содержимого не пройдена: %s", problem)
+	}
+
+	return ""
+}
+
+func statusExpected(expected []int, status int) bool {
+	if len(expected) == 0 {
+		return status >= 200 && status < 300
+	}
+
+	return slices.Contains(expected, status)
+}
+
+func authResultText(result *AuthResult) string {
+	if result.Err != nil {
+		return fmt.Sprintf("ошибка запроса с авторизацией: %s", result.Err.Error())
+	}
+
+	return fmt.Sprintf("код ответа с авторизацией: %d", result.StatusCode)
+}
+
+type response struct {
+	StatusCode    int
+	Header        http.Header
+	Body          []byte
+	BodyTruncated bool
+	Timings       Timings
+}
+
+// sendRequest sends a single request without following redirects and reads at most bodyLimit bytes of the response body.
+func sendRequest(ctx context.Context, client *http.Client, method string, address string, headers map[string]string, bodyLimit int64) (response, error) {
+	var result response
+
+	start := time.Now()
+	trace, timings := newTimingTrace(&start)
+	ctx = httptrace.WithClientTrace(ctx, trace)
+
+	req, err := http.NewRequestWithContext(ctx, method, address, nil)
+	if err != nil {
+		return result, err
+	}
+
+	setHeaders(req, headers)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		result.Timings = timings()
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	result.Timings = timings()
+	result.StatusCode = resp.StatusCode
+	result.Header = resp.Header
+
+	if bodyLimit > 0 {
+		result.Body, result.BodyTruncated, err = readBody(resp.Body, bodyLimit)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	result.Timings.Total = time.Since(start)
+
+	return result, nil
+}
+
+func setHeaders(req *http.Request, headers map[string]string) {
+	for name, value := range headers {
+		if strings.EqualFold(name, "Host") {
+			req.Host = value
+			continue
+		}
+
+		req.Header.Set(name, value)
+	}
+}
@@ -0,0 +1,137 @@
+package checker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunProbe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead || r.URL.Path != "/api/health" || r.Header.Get("X-Check") != "1" || r.Host != "api.example.com" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+
+	task := &Task{Site: site}
+	task.Connection.Addr = serverURL.Hostname()
+	task.Connection.Port = serverURL.Port()
+
+	probe := config.Probe{
+		Path:    "/api/health",
+		Method:  http.MethodHead,
+		Headers: map[string]string{"X-Check": "1", "Host": "api.example.com"},
+		Expect:  config.ExpectOpen,
+	}
+
+	result, err := runProbe(t.Context(), newClientSet(newTransportPool(config.Pool{}, nil, nil), task.Connection.Addr, config.Timeouts{}), task, probe)
+
+	assert.NoError(t, err)
+	assert.NoError(t, result.Err)
+	assert.Equal(t, "http://example.com/api/health", result.URL)
+	assert.Equal(t, http.StatusNoContent, result.StatusCode)
+	assert.Equal(t, "HEAD http://example.com/api/health", result.Name())
+	assert.Empty(t, evaluateProbe(task, &result))
+}
+
+func TestRunProbeWithBodyAssertions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html><h1>Account suspended</h1></html>"))
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+
+	task := &Task{Site: site, MaxBodySize: 16}
+	task.Connection.Addr = serverURL.Hostname()
+	task.Connection.Port = serverURL.Port()
+
+	probe := config.Probe{
+		Path:                   "/",
+		Method:                 http.MethodGet,
+		Expect:                 config.ExpectOpen,
+		BodyNotContains:        []string{"suspended"},
+		BodyNotContainsRegexps: []*regexp.Regexp{regexp.MustCompile("suspended")},
+	}
+
+	result, err := runProbe(t.Context(), newClientSet(newTransportPool(config.Pool{}, nil, nil), task.Connection.Addr, config.Timeouts{}), task, probe)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("<html><h1>Accoun"), result.Body)
+	assert.True(t, result.BodyTruncated)
+	assert.Empty(t, evaluateProbe(task, &result))
+
+	task.MaxBodySize = config.MaxBodySizeDefault
+
+	result, err = runProbe(t.Context(), newClientSet(newTransportPool(config.Pool{}, nil, nil), task.Connection.Addr, config.Timeouts{}), task, probe)
+
+	assert.NoError(t, err)
+	assert.False(t, result.BodyTruncated)
+	assert.Equal(t, `Проверка содержимого не пройдена: найдено совпадение с "suspended": "suspended"`, evaluateProbe(task, &result))
+}
+
+func TestEvaluateProbe(t *testing.T) {
+	task := &Task{
+		Site:            site,
+		PHPVersion:      "8.2.29 (alt)",
+		PHPHandler:      "PHP Apache 8.2.29 (alt)",
+		ErrorSignatures: phpErrorSignaturesDefault,
+	}
+	challenge := http.Header{"Www-Authenticate": []string{`Basic realm="Restricted"`}}
+
+	testCases := []struct {
+		name     string
+		result   ProbeResult
+		expected string
+	}{
+		{
+			name:   "closed",
+			result: ProbeResult{Probe: config.Probe{Expect: config.ExpectClosed}, StatusCode: http.StatusUnauthorized, Header: challenge},
+		},
+		{
+			name:     "closed expected, but open",
+			result:   ProbeResult{Probe: config.Probe{Expect: config.ExpectClosed}, StatusCode: http.StatusOK},
+			expected: "Код ответа: 200",
+		},
+		{
+			name:   "open with any 2xx",
+			result: ProbeResult{Probe: config.Probe{Expect: config.ExpectOpen}, StatusCode: http.StatusCreated},
+		},
+		{
+			name:     "open expected, but closed",
+			result:   ProbeResult{Probe: config.Probe{Expect: config.ExpectOpen}, StatusCode: http.StatusUnauthorized, Header: challenge},
+			expected: "Код ответа: 401",
+		},
+		{
+			name:     "open with php error",
+			result:   ProbeResult{Probe: config.Probe{Expect: config.ExpectOpen}, StatusCode: http.StatusOK, Body: []byte("<b>Parse error</b>: syntax error")},
+			expected: `Страница с ошибкой: "Parse error: syntax error" (PHP 8.2.29 (alt), обработчик PHP Apache 8.2.29 (alt))`,
+		},
+		{
+			name:   "open with expected status",
+			result: ProbeResult{Probe: config.Probe{Expect: config.ExpectOpen, Status: []int{http.StatusOK, http.StatusFound}}, StatusCode: http.StatusFound},
+		},
+		{
+			name:     "open with unexpected status",
+			result:   ProbeResult{Probe: config.Probe{Expect: config.ExpectOpen, Status: []int{http.StatusFound}}, StatusCode: http.StatusOK},
+			expected: "Код ответа: 200",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, evaluateProbe(task, &testCase.result))
+		})
+	}
+}
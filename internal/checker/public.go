@@ -0,0 +1,76 @@
+package checker
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+)
+
+// PublicResult is the response to a probe sent through the public DNS and the outbound or system proxy.
+type PublicResult struct {
+	StatusCode int
+	Err        error
+}
+
+// Exposed reports that the public path returned a working page.
+func (r *PublicResult) Exposed() bool {
+	return r != nil && r.Err == nil && r.StatusCode >= 200 && r.StatusCode < 300
+}
+
+// newPublicTransport resolves the site names as visitors do. It goes through the outbound proxy when it's set
+// and through the proxy from the environment otherwise, redirects are followed by the client up to the default limit.
+func newPublicTransport(timeouts config.Timeouts, outbound *outbound) *http.Transport {
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           outbound.dialer(timeouts),
+		TLSClientConfig:       &tls.Config{InsecureSkipVerify: true},
+		TLSHandshakeTimeout:   timeouts.TLSHandshake,
+		ResponseHeaderTimeout: timeouts.ResponseHeader,
+		IdleConnTimeout:       config.IdleTimeoutDefault,
+	}
+
+	// the dialer tunnels through the outbound proxy, it replaces the system one
+	if outbound.proxied() {
+		transport.Proxy = nil
+	}
+
+	return transport
+}
+
+// checkPublicPath repeats the probes that found the site closed through the public path.
+func checkPublicPath(ctx context.Context, clients *clientSet, task *Task) error {
+	client := clients.public()
+
+	for i := range task.Result.Probes {
+		result := &task.Result.Probes[i]
+		if !result.Closed() {
+			continue
+		}
+
+		resp, err := sendRequest(ctx, client, result.Probe.Method, result.URL, result.Probe.Headers, 0)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		result.Public = &PublicResult{StatusCode: resp.StatusCode, Err: err}
+	}
+
+	return nil
+}
+
+// exposureReport lists the probes closed on the server but open through the public path.
+func exposureReport(task *Task) string {
+	var lines []string
+
+	for i := range task.Result.Probes {
+		if result := &task.Result.Probes[i]; result.Closed() && result.Public.Exposed() {
+			lines = append(lines, fmt.Sprintf("%s - на сервере %d, через публичный путь %d", result.Name(), result.StatusCode, result.Public.StatusCode))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
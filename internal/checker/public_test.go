@@ -0,0 +1,108 @@
+package checker
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+	"github.com/kias-hack/isp-site-checker/internal/notify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCheckPublicPath(t *testing.T) {
+	direct := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer direct.Close()
+
+	testCases := []struct {
+		name    string
+		status  int
+		exposed bool
+	}{
+		{name: "open through cdn", status: http.StatusOK, exposed: true},
+		{name: "closed through cdn", status: http.StatusUnauthorized},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			public := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, site, r.Host)
+				w.WriteHeader(testCase.status)
+			}))
+			defer public.Close()
+
+			directURL, _ := url.Parse(direct.URL)
+			publicURL, _ := url.Parse(public.URL)
+
+			task := &Task{Site: site, Probes: config.ProbesDefault, PublicPath: true}
+			task.Connection.Addr = directURL.Hostname()
+			task.Connection.Port = directURL.Port()
+
+			pool := newTransportPool(config.Pool{}, nil, nil)
+			// the public path resolves the site to the public server
+			pool.public[config.Timeouts{}] = &http.Transport{
+				DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+					var dialer net.Dialer
+					return dialer.DialContext(ctx, network, publicURL.Host)
+				},
+			}
+
+			clients := newClientSet(pool, task.Connection.Addr, config.Timeouts{})
+
+			result, err := runProbe(t.Context(), clients, task, config.ProbesDefault[0])
+			require.NoError(t, err)
+			require.True(t, result.Closed())
+
+			task.Result.Probes = append(task.Result.Probes, result)
+
+			require.NoError(t, checkPublicPath(t.Context(), clients, task))
+			require.NotNil(t, task.Result.Probes[0].Public)
+			assert.Equal(t, testCase.status, task.Result.Probes[0].Public.StatusCode)
+			assert.Equal(t, testCase.exposed, exposureReport(task) != "")
+		})
+	}
+}
+
+func TestResultHandlerExposed(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	resultPipe := make(chan *Task)
+	wg := &sync.WaitGroup{}
+	notifierMock := notify.NewMockNotifier(gomock.NewController(t))
+
+	challenge := http.Header{"Www-Authenticate": []string{`Basic realm="Restricted"`}}
+
+	notifierMock.EXPECT().Exposed("example.com", gomock.Cond(func(message string) bool {
+		return assert.Contains(t, message, "GET http://example.com/ - на сервере 401, через публичный путь 200")
+	})).Times(1)
+
+	wg.Add(1)
+	go resultHandler(ctx, wg, resultPipe, notifierMock, nil, nil)
+
+	resultPipe <- &Task{
+		Site:  "example.com",
+		Owner: "root",
+		Result: Result{
+			Probes: []ProbeResult{{
+				Probe:      config.ProbesDefault[0],
+				URL:        "http://example.com/",
+				StatusCode: http.StatusUnauthorized,
+				Header:     challenge,
+				Public:     &PublicResult{StatusCode: http.StatusOK},
+			}},
+		},
+	}
+
+	cancel()
+	wg.Wait()
+}
@@ -0,0 +1,151 @@
+package checker
+
+import (
+	"expvar"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+)
+
+var recheckMetrics = expvar.NewMap("rechecks")
+
+// recheckQueue keeps the next check time of every failing site. The result handler adds and removes the sites,
+// the scheduler takes the due ones between the rounds.
+type recheckQueue struct {
+	interval    time.Duration
+	maxInFlight int
+
+	mu      sync.Mutex
+	sites   map[string]*recheckItem
+	running map[string]bool
+}
+
+type recheckItem struct {
+	task *Task
+	next time.Time
+}
+
+// newRecheckQueue returns nil when rechecks are disabled, the methods of a nil queue do nothing.
+func newRecheckQueue(cfg config.Recheck) *recheckQueue {
+	if cfg.Interval <= 0 {
+		return nil
+	}
+
+	return &recheckQueue{
+		interval:    cfg.Interval,
+		maxInFlight: max(cfg.MaxInFlight, 1),
+		sites:       make(map[string]*recheckItem),
+		running:     make(map[string]bool),
+	}
+}
+
+// tick is the period the scheduler looks for due rechecks with.
+func (q *recheckQueue) tick() time.Duration {
+	return min(q.interval, time.Second)
+}
+
+// update records the handled task: a failing site is rechecked after the interval, a working one is removed.
+func (q *recheckQueue) update(task *Task, failing bool) {
+	if q == nil {
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if task.Recheck {
+		delete(q.running, task.Site)
+	}
+
+	if !failing {
+		delete(q.sites, task.Site)
+		return
+	}
+
+	q.sites[task.Site] = &recheckItem{task: recheckTask(task), next: time.Now().Add(q.interval)}
+}
+
+// refresh replaces the queued tasks with the tasks of the new round and drops the sites that are gone.
+func (q *recheckQueue) refresh(tasks []*Task) {
+	if q == nil {
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	current := make(map[string]*Task, len(tasks))
+	for _, task := range tasks {
+		current[task.Site] = task
+	}
+
+	for site, item := range q.sites {
+		task, ok := current[site]
+		if !ok {
+			delete(q.sites, site)
+			continue
+		}
+
+		item.task = recheckTask(task)
+	}
+}
+
+// due returns the rechecks to send now, the longest waiting first. At most maxInFlight rechecks are running,
+// the rest wait for the next tick.
+func (q *recheckQueue) due(now time.Time) []*Task {
+	if q == nil {
+		return nil
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var items []*recheckItem
+
+	for site, item := range q.sites {
+		if !q.running[site] && !item.next.After(now) {
+			items = append(items, item)
+		}
+	}
+
+	slices.SortFunc(items, func(a, b *recheckItem) int {
+		return a.next.Compare(b.next)
+	})
+
+	free := max(q.maxInFlight-len(q.running), 0)
+	if len(items) > free {
+		slog.Debug("rechecks deferred by the in-flight limit", "component", "scheduler", "due", len(items), "free", free)
+		recheckMetrics.Add("deferred", int64(len(items)-free))
+		items = items[:free]
+	}
+
+	tasks := make([]*Task, 0, len(items))
+
+	for _, item := range items {
+		q.running[item.task.Site] = true
+		item.next = now.Add(q.interval)
+		tasks = append(tasks, recheckTask(item.task))
+	}
+
+	recheckMetrics.Add("sent", int64(len(tasks)))
+
+	return tasks
+}
+
+// recheckTask copies the task without its round and result, the workers fill the result of the copy.
+func recheckTask(task *Task) *Task {
+	recheck := *task
+	recheck.Round = nil
+	recheck.Scheduled = nil
+	recheck.Recheck = true
+	recheck.SensitiveFiles = nil
+	recheck.ListingPaths = nil
+	recheck.NoIndex = false
+	recheck.Audits = nil
+	recheck.Result = Result{}
+
+	return &recheck
+}
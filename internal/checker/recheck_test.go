@@ -0,0 +1,94 @@
+package checker
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+	"github.com/kias-hack/isp-site-checker/internal/isp"
+	"github.com/kias-hack/isp-site-checker/internal/notify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRecheckQueue(t *testing.T) {
+	assert.Nil(t, newRecheckQueue(config.Recheck{}))
+
+	q := newRecheckQueue(config.Recheck{Interval: 15 * time.Second, MaxInFlight: 2})
+	now := time.Now()
+
+	for _, site := range []string{"a.ru", "b.ru", "c.ru"} {
+		q.update(&Task{Site: site, Result: Result{Timestamp: now}}, true)
+	}
+	q.update(&Task{Site: "ok.ru"}, false)
+
+	assert.Empty(t, q.due(now), "rechecks are due after the interval")
+
+	tasks := q.due(now.Add(time.Minute))
+	require.Len(t, tasks, 2, "rechecks are limited by max_in_flight")
+
+	for _, task := range tasks {
+		assert.True(t, task.Recheck)
+		assert.Nil(t, task.Round)
+		assert.True(t, task.Result.Timestamp.IsZero())
+	}
+
+	assert.Empty(t, q.due(now.Add(time.Minute)), "running rechecks take all slots")
+
+	// the first recheck passed, the site is removed and frees its slot
+	q.update(tasks[0], false)
+
+	tasks = q.due(now.Add(time.Minute))
+	require.Len(t, tasks, 1)
+	assert.NotEqual(t, tasks[0].Site, "ok.ru")
+
+	// the site is gone from the domain list
+	q.refresh([]*Task{{Site: tasks[0].Site}})
+	assert.Len(t, q.sites, 1)
+	assert.Contains(t, q.sites, tasks[0].Site)
+
+	var disabled *recheckQueue
+	disabled.update(&Task{Site: "a.ru"}, true)
+	disabled.refresh(nil)
+	assert.Nil(t, disabled.due(now))
+}
+
+func TestSchedulerRechecks(t *testing.T) {
+	ctx := t.Context()
+	wg := &sync.WaitGroup{}
+
+	ticker := make(chan struct{})
+	taskPipe := make(chan *Task)
+
+	cfg := &config.Config{ScrapeInterval: time.Hour}
+	rechecks := newRecheckQueue(config.Recheck{Interval: 50 * time.Millisecond, MaxInFlight: 1})
+
+	wg.Add(1)
+	go scheduler(ctx, wg, cfg, ticker, taskPipe, func() ([]*isp.WebDomain, error) {
+		return []*isp.WebDomain{{Sites: []string{"fail.ru", "ok.ru"}}}, nil
+	}, notify.NewMockNotifier(gomock.NewController(t)), rechecks, nil)
+
+	ticker <- struct{}{}
+
+	for range 2 {
+		task := <-taskPipe
+		assert.False(t, task.Recheck)
+
+		rechecks.update(task, task.Site == "fail.ru")
+		task.Round.taskDone(task.Site == "fail.ru", false)
+	}
+
+	for range 3 {
+		select {
+		case task := <-taskPipe:
+			assert.True(t, task.Recheck)
+			assert.Equal(t, "fail.ru", task.Site)
+
+			rechecks.update(task, true)
+		case <-time.After(time.Second):
+			t.Fatal("failing site is not rechecked")
+		}
+	}
+}
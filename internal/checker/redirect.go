@@ -0,0 +1,183 @@
+package checker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+)
+
+type Redirect struct {
+	URL        string
+	StatusCode int
+	Location   string
+}
+
+// dialPort returns the server port for the address: the explicit port, the task port for the site scheme or the scheme default.
+func (t *Task) dialPort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+
+	if u.Scheme == schemeForPort(t.Connection.Port) {
+		return t.Connection.Port
+	}
+
+	if u.Scheme == "https" {
+		return defaultHTTPSPort
+	}
+
+	return defaultHTTPPort
+}
+
+func (t *Task) onServer(host string) bool {
+	return strings.EqualFold(host, t.Site) || t.ServerSites[strings.ToLower(host)]
+}
+
+func isRedirect(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+
+	return false
+}
+
+// fetch sends the probe request and follows redirects within the server according to the task redirect policy.
+// Every redirect is recorded into the result, the last response fills the result fields.
+func fetch(ctx context.Context, clients *clientSet, task *Task, result *ProbeResult, bodyLimit int64) error {
+	method := result.Probe.Method
+	current := result.URL
+	visited := map[string]bool{current: true}
+
+	var elapsed Timings
+
+	client := clients.get
+	if _, ok := result.Probe.Headers["Authorization"]; ok {
+		client = clients.verified
+	}
+
+	for {
+		currentURL, err := url.Parse(current)
+		if err != nil {
+			return err
+		}
+
+		resp, err := sendRequest(ctx, client(task.dialPort(currentURL)), method, current, result.Probe.Headers, bodyLimit)
+		if resp.Timings.TTFB > 0 {
+			resp.Timings.TTFB += elapsed.Total
+		}
+		resp.Timings.Total += elapsed.Total
+		result.Timings = resp.Timings
+
+		if err != nil {
+			return err
+		}
+
+		elapsed = resp.Timings
+
+		result.StatusCode = resp.StatusCode
+		result.Header = resp.Header
+		result.Body = resp.Body
+		result.BodyTruncated = resp.BodyTruncated
+
+		location := resp.Header.Get("Location")
+		if !isRedirect(resp.StatusCode) || location == "" {
+			return nil
+		}
+
+		target, err := currentURL.Parse(location)
+		if err != nil {
+			return fmt.Errorf("invalid redirect location %q: %w", location, err)
+		}
+
+		result.Redirects = append(result.Redirects, Redirect{URL: current, StatusCode: resp.StatusCode, Location: target.String()})
+
+		if visited[target.String()] {
+			result.RedirectLoop = true
+			return nil
+		}
+
+		if task.Redirects.Mode != config.RedirectFollow || len(result.Redirects) > task.Redirects.Max || !task.onServer(target.Hostname()) {
+			return nil
+		}
+
+		if resp.StatusCode <= http.StatusSeeOther && method != http.MethodGet && method != http.MethodHead {
+			method = http.MethodGet
+		}
+
+		visited[target.String()] = true
+		current = target.String()
+	}
+}
+
+// redirectProblem checks the recorded redirect chain against the task redirect rules.
+func redirectProblem(task *Task, result *ProbeResult) string {
+	policy := task.Redirects
+
+	if result.RedirectLoop {
+		return fmt.Sprintf("Цикл перенаправлений: %s", redirectChain(result))
+	}
+
+	if policy.Mode == config.RedirectFollow && len(result.Redirects) > policy.Max {
+		return fmt.Sprintf("Превышено число перенаправлений (%d): %s", policy.Max, redirectChain(result))
+	}
+
+	if policy.HasRule(config.RedirectRuleSameServer) {
+		for _, redirect := range result.Redirects {
+			target, _ := url.Parse(redirect.Location)
+			if !task.onServer(target.Hostname()) {
+				return fmt.Sprintf("Перенаправление за пределы сервера: %s", redirectChain(result))
+			}
+		}
+	}
+
+	original, _ := url.Parse(result.URL)
+
+	if policy.HasRule(config.RedirectRuleHTTPS) && original.Scheme == "http" {
+		if len(result.Redirects) == 0 {
+			return "Нет перенаправления на https"
+		}
+
+		first, _ := url.Parse(result.Redirects[0].Location)
+		if first.Scheme != "https" || first.Hostname() != original.Hostname() {
+			return fmt.Sprintf("Перенаправление на %s вместо https://%s", result.Redirects[0].Location, original.Hostname())
+		}
+	}
+
+	canonical := ""
+	bare := strings.TrimPrefix(task.Site, "www.")
+
+	switch {
+	case policy.HasRule(config.RedirectRuleWWW):
+		canonical = "www." + bare
+	case policy.HasRule(config.RedirectRuleNonWWW):
+		canonical = bare
+	}
+
+	if canonical != "" {
+		final := original
+		if len(result.Redirects) > 0 {
+			final, _ = url.Parse(result.Redirects[len(result.Redirects)-1].Location)
+		}
+
+		if final.Hostname() != canonical {
+			return fmt.Sprintf("Канонический адрес %s, получен %s", canonical, final.Hostname())
+		}
+	}
+
+	return ""
+}
+
+func redirectChain(result *ProbeResult) string {
+	chain := []string{result.URL}
+
+	for _, redirect := range result.Redirects {
+		chain = append(chain, fmt.Sprintf("%d %s", redirect.StatusCode, redirect.Location))
+	}
+
+	return strings.Join(chain, " -> ")
+}
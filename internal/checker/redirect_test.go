@@ -0,0 +1,192 @@
+package checker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRedirectTask(t *testing.T, handler http.HandlerFunc, policy config.RedirectPolicy) *Task {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	serverURL, _ := url.Parse(server.URL)
+
+	task := &Task{
+		Site:        "example.com",
+		Redirects:   policy,
+		ServerSites: map[string]bool{"example.com": true, "www.example.com": true},
+	}
+	task.Connection.Addr = serverURL.Hostname()
+	task.Connection.Port = serverURL.Port()
+
+	return task
+}
+
+func TestFetchFollowsRedirects(t *testing.T) {
+	var methods []string
+
+	task := newRedirectTask(t, func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+
+		if r.Host == "example.com" {
+			http.Redirect(w, r, "http://www.example.com/", http.StatusFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}, config.RedirectPolicy{Mode: config.RedirectFollow, Max: config.RedirectMaxDefault})
+
+	result, err := runProbe(t.Context(), newClientSet(newTransportPool(config.Pool{}, nil, nil), task.Connection.Addr, config.Timeouts{}), task, config.Probe{Path: "/", Method: http.MethodPost, Expect: config.ExpectOpen})
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Equal(t, []Redirect{{URL: "http://example.com/", StatusCode: http.StatusFound, Location: "http://www.example.com/"}}, result.Redirects)
+	assert.Equal(t, "http://www.example.com/", result.FinalURL())
+	assert.Equal(t, []string{http.MethodPost, http.MethodGet}, methods)
+	assert.Empty(t, evaluateProbe(task, &result))
+}
+
+func TestFetchDoesNotLeaveServer(t *testing.T) {
+	task := newRedirectTask(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "example.com" {
+			t.Errorf("unexpected request to %s", r.Host)
+		}
+
+		http.Redirect(w, r, "https://unprotected.test/", http.StatusMovedPermanently)
+	}, config.RedirectPolicy{Mode: config.RedirectFollow, Max: config.RedirectMaxDefault, Rules: []string{config.RedirectRuleSameServer}})
+
+	result, err := runProbe(t.Context(), newClientSet(newTransportPool(config.Pool{}, nil, nil), task.Connection.Addr, config.Timeouts{}), task, config.ProbesDefault[0])
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusMovedPermanently, result.StatusCode)
+	assert.Len(t, result.Redirects, 1)
+	assert.Equal(t, "Перенаправление за пределы сервера: http://example.com/ -> 301 https://unprotected.test/", evaluateProbe(task, &result))
+}
+
+func TestFetchRedirectLoop(t *testing.T) {
+	task := newRedirectTask(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+
+		http.Redirect(w, r, "/", http.StatusFound)
+	}, config.RedirectPolicy{Mode: config.RedirectFollow, Max: config.RedirectMaxDefault})
+
+	result, err := runProbe(t.Context(), newClientSet(newTransportPool(config.Pool{}, nil, nil), task.Connection.Addr, config.Timeouts{}), task, config.ProbesDefault[0])
+
+	assert.NoError(t, err)
+	assert.True(t, result.RedirectLoop)
+	assert.Len(t, result.Redirects, 2)
+	assert.Equal(t, "Цикл перенаправлений: http://example.com/ -> 302 http://example.com/login -> 302 http://example.com/", evaluateProbe(task, &result))
+}
+
+func TestFetchTooManyRedirects(t *testing.T) {
+	task := newRedirectTask(t, func(w http.ResponseWriter, r *http.Request) {
+		step, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+		http.Redirect(w, r, "/"+strconv.Itoa(step+1), http.StatusFound)
+	}, config.RedirectPolicy{Mode: config.RedirectFollow, Max: 2})
+
+	result, err := runProbe(t.Context(), newClientSet(newTransportPool(config.Pool{}, nil, nil), task.Connection.Addr, config.Timeouts{}), task, config.ProbesDefault[0])
+
+	assert.NoError(t, err)
+	assert.Len(t, result.Redirects, 3)
+	assert.Contains(t, evaluateProbe(task, &result), "Превышено число перенаправлений (2)")
+}
+
+func TestFetchRedirectNone(t *testing.T) {
+	task := newRedirectTask(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/login", http.StatusFound)
+	}, config.RedirectPolicy{Mode: config.RedirectNone, Max: config.RedirectMaxDefault})
+
+	result, err := runProbe(t.Context(), newClientSet(newTransportPool(config.Pool{}, nil, nil), task.Connection.Addr, config.Timeouts{}), task, config.ProbesDefault[0])
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusFound, result.StatusCode)
+	assert.Len(t, result.Redirects, 1)
+	assert.Equal(t, "Код ответа: 302", evaluateProbe(task, &result))
+}
+
+func TestRedirectProblem(t *testing.T) {
+	testCases := []struct {
+		name      string
+		site      string
+		rules     []string
+		url       string
+		redirects []string
+		problem   string
+	}{
+		{
+			name:      "https redirect on the same host",
+			site:      "example.com",
+			rules:     []string{config.RedirectRuleHTTPS},
+			url:       "http://example.com/",
+			redirects: []string{"https://example.com/"},
+		},
+		{
+			name:    "no https redirect",
+			site:    "example.com",
+			rules:   []string{config.RedirectRuleHTTPS},
+			url:     "http://example.com/",
+			problem: "Нет перенаправления на https",
+		},
+		{
+			name:      "https redirect to another host",
+			site:      "example.com",
+			rules:     []string{config.RedirectRuleHTTPS},
+			url:       "http://example.com/",
+			redirects: []string{"https://www.example.com/"},
+			problem:   "Перенаправление на https://www.example.com/ вместо https://example.com",
+		},
+		{
+			name:  "https rule is skipped for https url",
+			site:  "example.com",
+			rules: []string{config.RedirectRuleHTTPS},
+			url:   "https://example.com/",
+		},
+		{
+			name:      "www canonical host",
+			site:      "example.com",
+			rules:     []string{config.RedirectRuleWWW},
+			url:       "http://example.com/",
+			redirects: []string{"http://www.example.com/"},
+		},
+		{
+			name:    "www canonical host without redirect",
+			site:    "example.com",
+			rules:   []string{config.RedirectRuleWWW},
+			url:     "http://example.com/",
+			problem: "Канонический адрес www.example.com, получен example.com",
+		},
+		{
+			name:      "non www canonical host",
+			site:      "www.example.com",
+			rules:     []string{config.RedirectRuleNonWWW},
+			url:       "http://www.example.com/",
+			redirects: []string{"http://example.com/"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			task := &Task{
+				Site:      testCase.site,
+				Redirects: config.RedirectPolicy{Mode: config.RedirectFollow, Max: config.RedirectMaxDefault, Rules: testCase.rules},
+			}
+
+			result := &ProbeResult{URL: testCase.url}
+			for _, location := range testCase.redirects {
+				result.Redirects = append(result.Redirects, Redirect{StatusCode: http.StatusMovedPermanently, Location: location})
+			}
+
+			assert.Equal(t, testCase.problem, redirectProblem(task, result))
+		})
+	}
+}
@@ -4,47 +4,249 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
-	"net/http"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/kias-hack/isp-site-checker/internal/config"
 	"github.com/kias-hack/isp-site-checker/internal/notify"
 )
 
-func resultHandler(ctx context.Context, wg *sync.WaitGroup, resultPipe <-chan *Task, notifier notify.Notifier) {
+func resultHandler(ctx context.Context, wg *sync.WaitGroup, resultPipe <-chan *Task, notifier notify.Notifier, rechecks *recheckQueue, siteList *siteList) {
 	defer wg.Done()
 
+	states := make(map[string]*siteState)
+	var listVersion uint64
+	sensitive := newFindingReporter(notify.FindingSensitiveFiles, "Найдены открытые служебные файлы (репозитории, настройки, дампы)",
+		"Открытые служебные файлы на сайтах владельца %s больше не найдены")
+	listings := newFindingReporter(notify.FindingDirectoryListing, "Найдены открытые списки файлов каталогов (Index of)",
+		"Открытые списки файлов каталогов на сайтах владельца %s больше не найдены")
+	noindex := newFindingReporter(notify.FindingNoIndex, "Сайты не в продакшене не защищены от индексации поисковиками",
+		"Сайты владельца %s защищены от индексации поисковиками")
+
 	for {
 		select {
 		case task := <-resultPipe:
 			logger := slog.With("component", "resultHandler", "site", task.Site, "owner", task.Owner)
 
-			if task.Result.Err == nil && task.Result.StatusCode == http.StatusUnauthorized {
-				notifier.Success(task.Site, fmt.Sprintf("Сайт %s закрыт - %d\r\nВладелец - %s", task.Site, task.Result.StatusCode, task.Owner))
-				logger.Debug("result received, site closed")
+			logger.Debug("result received, processing")
+
+			if sites, version, ok := siteList.since(listVersion); ok {
+				listVersion = version
+				forgetSites(states, sites)
+			}
+
+			var problems []string
+
+			for i := range task.Result.Probes {
+				result := &task.Result.Probes[i]
+
+				if problem := evaluateProbe(task, result); problem != "" {
+					logger.Debug("probe failed", "probe", result.Name(), "problem", problem)
+					problems = append(problems, fmt.Sprintf("%s - %s", result.Name(), problem))
+				}
+			}
+
+			if len(task.Result.Probes) == 0 {
+				problems = append(problems, "Нет результатов проверок")
+			}
+
+			if report := bypassReport(task.Result.Bypass); report != "" {
+				logger.Debug("auth bypass detected")
+				problems = append(problems, fmt.Sprintf("Авторизацию можно обойти запросами:\n%s", report))
+			}
+
+			// the audits are finished before the round, so the next round sees them done
+			task.Audits.finish(task, time.Now())
+			task.Round.taskDone(len(problems) > 0, false)
+			task.Scheduled.finished()
+			recordTimings(task)
+
+			if found, ok := sensitiveFound(task); ok {
+				sensitive.report(notifier, task, found)
+			}
+
+			if found, ok := listingsFound(task); ok {
+				listings.report(notifier, task, found)
+			}
+
+			if found, ok := noindexFound(task); ok {
+				noindex.report(notifier, task, found)
+			}
+
+			state, ok := states[task.Site]
+			if !ok {
+				state = &siteState{}
+				states[task.Site] = state
+			}
+
+			failed := len(problems) > 0
+			failing := state.record(task.Failure, failed)
+			rechecks.update(task, failed || failing)
+
+			if failed {
+				if !failing {
+					logger.Debug("failure is not confirmed yet", "history", state.history)
+					continue
+				}
+
+				state.failMessage = buildFailMessage(task, strings.Join(problems, "\n"))
+				notifier.Fail(task.Site, state.failMessage)
 				continue
 			}
 
-			logger.Debug("result received, processing")
+			if failing {
+				logger.Debug("recovery is not confirmed yet", "passed", state.passed)
+				notifier.Fail(task.Site, state.failMessage)
+				continue
+			}
 
-			msg := strings.Builder{}
+			if report := exposureReport(task); report != "" {
+				logger.Debug("site is exposed through the public path")
+				notifier.Exposed(task.Site, buildExposedMessage(task, report))
+				continue
+			}
+
+			var slow []string
 
-			msg.WriteString("Проверка домена выявила проблему\n")
-			msg.WriteString(fmt.Sprintf("Сайт: %s\n", task.Site))
-			msg.WriteString(fmt.Sprintf("Владелец: %s\n", task.Owner))
-			msg.WriteString(fmt.Sprintf("Время: %s\n", task.Result.Timestamp.Format("02.01.2006 15:04:05")))
+			for i := range task.Result.Probes {
+				result := &task.Result.Probes[i]
 
-			if task.Result.Err != nil {
-				logger.Debug("error in result", "err", task.Result.Err)
-				msg.WriteString(fmt.Sprintf("Произошла ошибка: %s", task.Result.Err.Error()))
-			} else {
-				logger.Debug("invalid status in result", "status_code", task.Result.StatusCode)
-				msg.WriteString(fmt.Sprintf("Код ответа: %d", task.Result.StatusCode))
+				if reason := slowReason(task.Latency, result.Timings); reason != "" {
+					logger.Debug("probe is slow", "probe", result.Name(), "timings", result.Timings)
+					slow = append(slow, fmt.Sprintf("%s - %s", result.Name(), reason))
+				}
 			}
 
-			notifier.Fail(task.Site, msg.String())
+			if len(slow) > 0 {
+				notifier.Slow(task.Site, buildSlowMessage(task, strings.Join(slow, "\n")))
+				continue
+			}
+
+			logger.Debug("all probes passed")
+			notifier.Success(task.Site, buildSuccessMessage(task))
 		case <-ctx.Done():
 			return
 		}
 	}
 }
+
+func buildSuccessMessage(task *Task) string {
+	var closed *ProbeResult
+
+	for i := range task.Result.Probes {
+		result := &task.Result.Probes[i]
+
+		if !result.Probe.IsHTTP() {
+			continue
+		}
+
+		if result.Probe.Expect != config.ExpectClosed {
+			closed = nil
+			break
+		}
+
+		if closed == nil {
+			closed = result
+		}
+	}
+
+	if closed == nil {
+		return fmt.Sprintf("Сайт %s работает, пройдено проверок: %d\r\nВладелец - %s", task.Site, len(task.Result.Probes), task.Owner)
+	}
+
+	return fmt.Sprintf("Сайт %s закрыт - %d\r\nВладелец - %s", task.Site, closed.StatusCode, task.Owner)
+}
+
+func buildFailMessage(task *Task, reason string) string {
+	return buildMessage("Проверка домена выявила проблему", task, reason)
+}
+
+func buildExposedMessage(task *Task, reason string) string {
+	return buildMessage("Сайт закрыт на сервере, но открыт через публичный адрес (CDN или другой фронтенд)", task, reason)
+}
+
+func buildSlowMessage(task *Task, reason string) string {
+	return buildMessage("Сайт отвечает медленно", task, reason)
+}
+
+func buildMessage(title string, task *Task, reason string) string {
+	msg := strings.Builder{}
+
+	msg.WriteString(title + "\n")
+	msg.WriteString(fmt.Sprintf("Сайт: %s\n", task.Site))
+	msg.WriteString(fmt.Sprintf("Владелец: %s\n", task.Owner))
+	msg.WriteString(fmt.Sprintf("Время: %s\n", task.Result.Timestamp.Format("02.01.2006 15:04:05")))
+	msg.WriteString(reason)
+
+	var timings []string
+
+	for i := range task.Result.Probes {
+		if result := &task.Result.Probes[i]; result.Timings.Total > 0 {
+			timings = append(timings, fmt.Sprintf("%s - %s", result.Name(), result.Timings))
+		}
+	}
+
+	if len(timings) > 0 {
+		msg.WriteString("\nВремя ответа:\n")
+		msg.WriteString(strings.Join(timings, "\n"))
+	}
+
+	return msg.String()
+}
+
+// siteList keeps the sites of the last loaded domain list. The scheduler replaces it, the result handler forgets
+// the sites that are gone.
+type siteList struct {
+	mu      sync.Mutex
+	version uint64
+	sites   map[string]bool
+}
+
+func newSiteList() *siteList {
+	return &siteList{}
+}
+
+// set replaces the sites with the sites of the tasks, the methods of a nil list do nothing.
+func (l *siteList) set(tasks []*Task) {
+	if l == nil {
+		return
+	}
+
+	sites := make(map[string]bool, len(tasks))
+	for _, task := range tasks {
+		sites[task.Site] = true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sites = sites
+	l.version++
+}
+
+// since returns the sites when the list was replaced after the version.
+func (l *siteList) since(version uint64) (map[string]bool, uint64, bool) {
+	if l == nil {
+		return nil, version, false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.version == version {
+		return nil, version, false
+	}
+
+	return l.sites, l.version, true
+}
+
+// forgetSites drops the failure history and the timing metrics of the sites that are gone from the domain list.
+func forgetSites(states map[string]*siteState, sites map[string]bool) {
+	for site := range states {
+		if !sites[site] {
+			delete(states, site)
+			timingMetrics.Delete(site)
+		}
+	}
+}
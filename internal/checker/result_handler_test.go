@@ -7,7 +7,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/kias-hack/isp-site-checker/internal/config"
 	"github.com/kias-hack/isp-site-checker/internal/notify"
+	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
 )
 
@@ -22,7 +24,7 @@ func TestResultHandlerLifecycle(t *testing.T) {
 	notifierStub.EXPECT().Success(gomock.Any(), gomock.Any()).Times(0)
 
 	wg.Add(1)
-	go resultHandler(ctx, wg, resultPipe, notifierStub)
+	go resultHandler(ctx, wg, resultPipe, notifierStub, nil, nil)
 
 	cancel()
 
@@ -49,6 +51,12 @@ func TestResultCases(t *testing.T) {
 	defer ctrl.Finish()
 	notifierMock := notify.NewMockNotifier(ctrl)
 
+	closedProbe := config.Probe{Path: "/", Method: http.MethodGet, Expect: config.ExpectClosed}
+	healthProbe := config.Probe{Path: "/api/health", Method: http.MethodGet, Expect: config.ExpectOpen}
+	challenge := http.Header{"Www-Authenticate": []string{`Basic realm="Restricted"`}}
+	timestamp := time.Date(2026, 2, 6, 1, 1, 1, 1, time.UTC)
+	failHeader := "Проверка домена выявила проблему\nСайт: example.com\nВладелец: root\nВремя: 06.02.2026 01:01:01\n"
+
 	testCases := []struct {
 		name           string
 		expectedMethod string
@@ -63,12 +71,10 @@ func TestResultCases(t *testing.T) {
 				Site:       "example.com",
 				DomainName: "example.com",
 				Owner:      "root",
-				Result: struct {
-					StatusCode int
-					Err        error
-					Timestamp  time.Time
-				}{
-					StatusCode: http.StatusUnauthorized,
+				Result: Result{
+					Probes: []ProbeResult{
+						{Probe: closedProbe, URL: "http://example.com/", StatusCode: http.StatusUnauthorized, Header: challenge},
+					},
 				},
 			},
 			expectedText: "Сайт example.com закрыт - 401\r\nВладелец - root",
@@ -81,16 +87,178 @@ func TestResultCases(t *testing.T) {
 				Site:       "example.com",
 				DomainName: "example.com",
 				Owner:      "root",
-				Result: struct {
-					StatusCode int
-					Err        error
-					Timestamp  time.Time
-				}{
-					StatusCode: http.StatusOK,
-					Timestamp:  time.Date(2026, 2, 6, 1, 1, 1, 1, time.UTC),
+				Result: Result{
+					Probes: []ProbeResult{
+						{Probe: closedProbe, URL: "http://example.com/", StatusCode: http.StatusOK},
+					},
+					Timestamp: timestamp,
+				},
+			},
+			expectedText: failHeader + "GET http://example.com/ - Код ответа: 200",
+		},
+		{
+			name:           "401 without auth challenge",
+			expectedMethod: "Fail",
+			task: &Task{
+				DomainId:   1,
+				Site:       "example.com",
+				DomainName: "example.com",
+				Owner:      "root",
+				Result: Result{
+					Probes: []ProbeResult{
+						{Probe: closedProbe, URL: "http://example.com/", StatusCode: http.StatusUnauthorized},
+					},
+					Timestamp: timestamp,
+				},
+			},
+			expectedText: failHeader + "GET http://example.com/ - Код ответа 401 без запроса авторизации: no Basic or Digest challenge in WWW-Authenticate",
+		},
+		{
+			name:           "401 with auth bypass",
+			expectedMethod: "Fail",
+			task: &Task{
+				DomainId:   1,
+				Site:       "example.com",
+				DomainName: "example.com",
+				Owner:      "root",
+				Result: Result{
+					Probes: []ProbeResult{
+						{Probe: closedProbe, URL: "http://example.com/", StatusCode: http.StatusUnauthorized, Header: challenge},
+					},
+					Bypass: []BypassResult{
+						{Method: http.MethodPost, URL: "http://example.com/", StatusCode: http.StatusOK},
+						{Method: http.MethodGet, URL: "http://example.com/index.php", StatusCode: http.StatusUnauthorized},
+					},
+					Timestamp: timestamp,
+				},
+			},
+			expectedText: failHeader + "Авторизацию можно обойти запросами:\nPOST http://example.com/ - 200",
+		},
+		{
+			name:           "401 and broken behind auth",
+			expectedMethod: "Fail",
+			task: &Task{
+				DomainId:   1,
+				Site:       "example.com",
+				DomainName: "example.com",
+				Owner:      "root",
+				Result: Result{
+					Probes: []ProbeResult{
+						{
+							Probe:         closedProbe,
+							URL:           "http://example.com/",
+							StatusCode:    http.StatusUnauthorized,
+							Header:        challenge,
+							Authenticated: &AuthResult{StatusCode: http.StatusInternalServerError},
+						},
+					},
+					Timestamp: timestamp,
 				},
 			},
-			expectedText: "Проверка домена выявила проблему\nСайт: example.com\nВладелец: root\nВремя: 06.02.2026 01:01:01\nКод ответа: 200",
+			expectedText: failHeader + "GET http://example.com/ - Сайт закрыт, но не работает: код ответа с авторизацией: 500",
+		},
+		{
+			name:           "401 with unexpected realm",
+			expectedMethod: "Fail",
+			task: &Task{
+				DomainId:   1,
+				Site:       "example.com",
+				DomainName: "example.com",
+				Owner:      "root",
+				AuthRealm:  "Staging",
+				Result: Result{
+					Probes: []ProbeResult{
+						{Probe: closedProbe, URL: "http://example.com/", StatusCode: http.StatusUnauthorized, Header: challenge},
+					},
+					Timestamp: timestamp,
+				},
+			},
+			expectedText: failHeader + "GET http://example.com/ - Код ответа 401 без запроса авторизации: unexpected realm \"Restricted\", expected \"Staging\"",
+		},
+		{
+			name:           "several probes, one failed",
+			expectedMethod: "Fail",
+			task: &Task{
+				DomainId:   1,
+				Site:       "example.com",
+				DomainName: "example.com",
+				Owner:      "root",
+				Result: Result{
+					Probes: []ProbeResult{
+						{Probe: closedProbe, URL: "http://example.com/", StatusCode: http.StatusUnauthorized, Header: challenge},
+						{Probe: healthProbe, URL: "http://example.com/api/health", StatusCode: http.StatusBadGateway},
+					},
+					Timestamp: timestamp,
+				},
+			},
+			expectedText: failHeader + "GET http://example.com/api/health - Код ответа: 502",
+		},
+		{
+			name:           "several probes passed",
+			expectedMethod: "Success",
+			task: &Task{
+				DomainId:   1,
+				Site:       "example.com",
+				DomainName: "example.com",
+				Owner:      "root",
+				Result: Result{
+					Probes: []ProbeResult{
+						{Probe: closedProbe, URL: "http://example.com/", StatusCode: http.StatusUnauthorized, Header: challenge},
+						{Probe: healthProbe, URL: "http://example.com/api/health", StatusCode: http.StatusOK},
+					},
+				},
+			},
+			expectedText: "Сайт example.com работает, пройдено проверок: 2\r\nВладелец - root",
+		},
+		{
+			name:           "slow",
+			expectedMethod: "Slow",
+			task: &Task{
+				DomainId:   1,
+				Site:       "example.com",
+				DomainName: "example.com",
+				Owner:      "root",
+				Latency:    config.LatencyThresholds{Total: time.Second},
+				Result: Result{
+					Probes: []ProbeResult{
+						{
+							Probe:      closedProbe,
+							URL:        "http://example.com/",
+							StatusCode: http.StatusUnauthorized,
+							Header:     challenge,
+							Timings:    Timings{Connect: 5 * time.Millisecond, TTFB: 1500 * time.Millisecond, Total: 1501 * time.Millisecond},
+						},
+					},
+					Timestamp: timestamp,
+				},
+			},
+			expectedText: "Сайт отвечает медленно\nСайт: example.com\nВладелец: root\nВремя: 06.02.2026 01:01:01\n" +
+				"GET http://example.com/ - ответ за 1.501s, порог 1s\n" +
+				"Время ответа:\nGET http://example.com/ - соединение 5ms, TLS 0s, первый байт 1.5s, всего 1.501s",
+		},
+		{
+			name:           "fail with timings",
+			expectedMethod: "Fail",
+			task: &Task{
+				DomainId:   1,
+				Site:       "example.com",
+				DomainName: "example.com",
+				Owner:      "root",
+				Latency:    config.LatencyThresholds{Total: time.Second},
+				Result: Result{
+					Probes: []ProbeResult{
+						{
+							Probe:      closedProbe,
+							URL:        "http://example.com/",
+							StatusCode: http.StatusOK,
+							Timings:    Timings{Connect: 5 * time.Millisecond, TTFB: 1500 * time.Millisecond, Total: 1501 * time.Millisecond},
+						},
+					},
+					Timestamp: timestamp,
+				},
+			},
+			expectedText: failHeader + "GET http://example.com/ - Код ответа: 200\n" +
+				"Время ответа:\nGET http://example.com/ - соединение 5ms, TLS 0s, первый байт 1.5s, всего 1.501s",
 		},
 	}
 
@@ -98,7 +266,7 @@ func TestResultCases(t *testing.T) {
 		t.Run(testCase.name, func(t *testing.T) {
 			ctx, cancel := context.WithCancel(t.Context())
 			wg.Add(1)
-			go resultHandler(ctx, wg, resultPipe, notifierMock)
+			go resultHandler(ctx, wg, resultPipe, notifierMock, nil, nil)
 			defer cancel()
 
 			if testCase.expectedMethod == "Fail" {
@@ -113,6 +281,12 @@ func TestResultCases(t *testing.T) {
 				notifierMock.EXPECT().Success(gomock.Any(), gomock.Any()).Times(0)
 			}
 
+			if testCase.expectedMethod == "Slow" {
+				notifierMock.EXPECT().Slow(testCase.task.Site, testCase.expectedText).Times(1)
+			} else {
+				notifierMock.EXPECT().Slow(gomock.Any(), gomock.Any()).Times(0)
+			}
+
 			resultPipe <- testCase.task
 		})
 	}
@@ -131,3 +305,102 @@ func TestResultCases(t *testing.T) {
 	case <-exit:
 	}
 }
+
+func TestResultHandlerFailureThreshold(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	resultPipe := make(chan *Task)
+	wg := &sync.WaitGroup{}
+	ctrl := gomock.NewController(t)
+	notifierMock := notify.NewMockNotifier(ctrl)
+
+	policy := config.FailurePolicy{Threshold: 2, Recovery: 2}
+	closedProbe := config.Probe{Path: "/", Method: http.MethodGet, Expect: config.ExpectClosed}
+	challenge := http.Header{"Www-Authenticate": []string{`Basic realm="Restricted"`}}
+
+	newTask := func(status int) *Task {
+		return &Task{
+			Site:    "example.com",
+			Owner:   "root",
+			Failure: policy,
+			Result: Result{
+				Probes: []ProbeResult{{Probe: closedProbe, URL: "http://example.com/", StatusCode: status, Header: challenge}},
+			},
+		}
+	}
+
+	gomock.InOrder(
+		notifierMock.EXPECT().Fail("example.com", gomock.Any()).Times(2),
+		notifierMock.EXPECT().Success("example.com", gomock.Any()).Times(1),
+	)
+
+	wg.Add(1)
+	go resultHandler(ctx, wg, resultPipe, notifierMock, nil, nil)
+
+	// the first failure is not confirmed, the second one is
+	resultPipe <- newTask(http.StatusOK)
+	resultPipe <- newTask(http.StatusOK)
+	// the first passed check keeps the failure, the second one confirms recovery
+	resultPipe <- newTask(http.StatusUnauthorized)
+	resultPipe <- newTask(http.StatusUnauthorized)
+
+	cancel()
+	wg.Wait()
+}
+
+func TestResultHandlerForgetsRemovedSites(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	resultPipe := make(chan *Task)
+	wg := &sync.WaitGroup{}
+	notifierMock := notify.NewMockNotifier(gomock.NewController(t))
+	sites := newSiteList()
+
+	policy := config.FailurePolicy{Threshold: 2, Recovery: 1}
+	probe := config.Probe{Path: "/", Method: http.MethodGet, Expect: config.ExpectOpen}
+
+	newTask := func(site string, status int) *Task {
+		return &Task{
+			Site:    site,
+			Failure: policy,
+			Result: Result{
+				Probes: []ProbeResult{{Probe: probe, URL: "http://" + site + "/", StatusCode: status, Timings: Timings{Total: time.Millisecond}}},
+			},
+		}
+	}
+
+	// the failure history of the removed site is dropped, its failure after the return is the first one
+	notifierMock.EXPECT().Fail(gomock.Any(), gomock.Any()).Times(0)
+	notifierMock.EXPECT().Success("other.example.com", gomock.Any()).Times(2)
+
+	wg.Add(1)
+	go resultHandler(ctx, wg, resultPipe, notifierMock, nil, sites)
+
+	sites.set([]*Task{{Site: "removed.example.com"}, {Site: "other.example.com"}})
+	resultPipe <- newTask("removed.example.com", http.StatusBadGateway)
+	// the next result is taken when the previous one is handled
+	resultPipe <- newTask("other.example.com", http.StatusOK)
+
+	sites.set([]*Task{{Site: "other.example.com"}})
+	resultPipe <- newTask("other.example.com", http.StatusOK)
+
+	sites.set([]*Task{{Site: "removed.example.com"}, {Site: "other.example.com"}})
+	resultPipe <- newTask("removed.example.com", http.StatusBadGateway)
+
+	cancel()
+	wg.Wait()
+}
+
+func TestForgetSites(t *testing.T) {
+	task := &Task{Site: "gone.example.com"}
+	task.Result.Probes = []ProbeResult{{Timings: Timings{Total: time.Millisecond}}}
+	recordTimings(task)
+
+	states := map[string]*siteState{"gone.example.com": {}, "kept.example.com": {}}
+	forgetSites(states, map[string]bool{"kept.example.com": true})
+
+	assert.Equal(t, map[string]*siteState{"kept.example.com": {}}, states)
+	assert.Nil(t, timingMetrics.Get("gone.example.com"))
+}
@@ -0,0 +1,141 @@
+package checker
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// roundsNotificationSite is the notifier key of the round overrun alerts.
+const roundsNotificationSite = "isp-site-checker"
+
+var roundMetrics = expvar.NewMap("rounds")
+
+// round is one pass over all sites. It finishes when every dispatched task is handled or dropped.
+type round struct {
+	ID    uint64
+	Start time.Time
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu         sync.Mutex
+	end        time.Time
+	dispatched bool
+	sent       int
+	handled    int
+	errors     int
+	cancelled  int
+	overran    bool
+}
+
+func newRound(ctx context.Context, id uint64) *round {
+	ctx, cancel := context.WithCancel(ctx)
+
+	return &round{
+		ID:     id,
+		Start:  time.Now(),
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+}
+
+// context returns the context of the round requests, ctx when the task is not bound to a round.
+func (r *round) context(ctx context.Context) context.Context {
+	if r == nil {
+		return ctx
+	}
+
+	return r.ctx
+}
+
+// dispatchFinished records the number of tasks sent to the workers, tasks not sent are counted as cancelled.
+func (r *round) dispatchFinished(sent int, total int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.dispatched = true
+	r.sent = sent
+	r.cancelled += total - sent
+	r.finishLocked()
+}
+
+// taskDone records the handled task, a nil round is ignored.
+func (r *round) taskDone(failed bool, cancelled bool) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.handled++
+
+	switch {
+	case cancelled:
+		r.cancelled++
+	case failed:
+		r.errors++
+	}
+
+	r.finishLocked()
+}
+
+func (r *round) markOverran() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.overran = true
+}
+
+func (r *round) overrun() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.overran
+}
+
+func (r *round) finishLocked() {
+	if !r.dispatched || r.handled < r.sent || !r.end.IsZero() {
+		return
+	}
+
+	r.end = time.Now()
+	r.cancel()
+	close(r.done)
+
+	duration := r.end.Sub(r.Start)
+
+	slog.Info("round finished", "component", "scheduler", "round", r.ID, "start", r.Start, "end", r.end, "duration", duration,
+		"tasks", r.sent, "errors", r.errors, "cancelled", r.cancelled, "overran", r.overran)
+
+	roundMetrics.Set("last_id", expvarInt(int64(r.ID)))
+	roundMetrics.Set("last_tasks", expvarInt(int64(r.sent)))
+	roundMetrics.Set("last_errors", expvarInt(int64(r.errors)))
+	roundMetrics.Set("last_cancelled", expvarInt(int64(r.cancelled)))
+	roundMetrics.Set("last_duration_seconds", expvarFloat(duration.Seconds()))
+}
+
+func overrunMessage(current *round, interval time.Duration, overruns int) string {
+	return fmt.Sprintf("Раунды проверки не укладываются в интервал %s: %d подряд, раунд #%d идёт %s",
+		interval, overruns, current.ID, time.Since(current.Start).Round(time.Second))
+}
+
+func expvarInt(value int64) *expvar.Int {
+	result := new(expvar.Int)
+	result.Set(value)
+
+	return result
+}
+
+func expvarFloat(value float64) *expvar.Float {
+	result := new(expvar.Float)
+	result.Set(value)
+
+	return result
+}
@@ -0,0 +1,40 @@
+package checker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoundFinish(t *testing.T) {
+	r := newRound(t.Context(), 7)
+
+	r.taskDone(true, false)
+	r.taskDone(false, true)
+
+	select {
+	case <-r.done:
+		t.Fatal("round finished before dispatching ended")
+	default:
+	}
+
+	r.dispatchFinished(3, 5)
+
+	select {
+	case <-r.done:
+		t.Fatal("round finished with a task in progress")
+	default:
+	}
+
+	r.taskDone(false, false)
+
+	<-r.done
+	assert.Error(t, r.ctx.Err())
+	assert.Equal(t, 1, r.errors)
+	assert.Equal(t, 3, r.cancelled)
+	assert.False(t, r.end.IsZero())
+
+	var empty *round
+	assert.Equal(t, t.Context(), empty.context(t.Context()))
+	empty.taskDone(true, false)
+}
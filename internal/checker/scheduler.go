@@ -1,50 +1,341 @@
 package checker
 
 import (
+	"cmp"
 	"context"
+	"fmt"
+	"hash/fnv"
 	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/kias-hack/isp-site-checker/internal/config"
 	"github.com/kias-hack/isp-site-checker/internal/isp"
+	"github.com/kias-hack/isp-site-checker/internal/notify"
 )
 
-func scheduler(ctx context.Context, wg *sync.WaitGroup, ticker <-chan struct{}, taskPipe chan<- *Task, getDomains isp.GetWebDomainsFunc) {
+func scheduler(ctx context.Context, wg *sync.WaitGroup, cfg *config.Config, ticker <-chan struct{}, taskPipe chan<- *Task, getDomains isp.GetWebDomainsFunc, notifier notify.Notifier, rechecks *recheckQueue, siteList *siteList) {
 	defer wg.Done()
 
+	errorSignatures := phpErrorSignatures(cfg)
+
+	var (
+		current   *round
+		lastID    uint64
+		pending   bool
+		overruns  int
+		alerted   bool
+		roundDone <-chan struct{}
+		recheck   <-chan time.Time
+	)
+
+	if rechecks != nil {
+		recheckTicker := time.NewTicker(rechecks.tick())
+		defer recheckTicker.Stop()
+
+		recheck = recheckTicker.C
+	}
+
+	sites := newSiteQueue()
+	audits := newAudits(cfg)
+
+	siteTimer := time.NewTimer(0)
+	siteTimer.Stop()
+	defer siteTimer.Stop()
+
+	resetSiteTimer := func() {
+		if next, ok := sites.next(); ok {
+			siteTimer.Reset(time.Until(next))
+			return
+		}
+
+		siteTimer.Stop()
+	}
+
+	// loadTasks fetches the domain list, the sites with their own schedule go to the site queue,
+	// the rest are returned for the round
+	loadTasks := func() ([]*Task, bool) {
+		slog.Debug("fetching domain list", "component", "scheduler")
+
+		domains, err := getDomains()
+		if err != nil {
+			slog.Error("failed to get domain list from ISPManager", "err", err, "component", "scheduler")
+			return nil, false
+		}
+
+		tasks := buildTasks(cfg, domains, errorSignatures)
+		rechecks.refresh(tasks)
+		siteList.set(tasks)
+		audits.refresh(tasks, time.Now())
+		tasks = sites.refresh(cfg, tasks, time.Now())
+		resetSiteTimer()
+
+		return tasks, true
+	}
+
+	dispatchNow := func(tasks []*Task) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dispatchTasks(ctx, tasks, make([]time.Duration, len(tasks)), taskPipe)
+		}()
+	}
+
+	startRound := func() {
+		slog.Debug("starting domain check", "component", "scheduler")
+
+		tasks, ok := loadTasks()
+		if !ok {
+			return
+		}
+
+		lastID++
+		current = newRound(ctx, lastID)
+		roundDone = current.done
+
+		audits.mark(tasks, time.Now())
+		offsets := scheduleOffsets(cfg.Schedule, tasks)
+
+		for _, task := range tasks {
+			task.Round = current
+		}
+
+		slog.Debug("round started", "component", "scheduler", "round", current.ID, "tasks", len(tasks))
+
+		wg.Add(1)
+		go func(r *round) {
+			defer wg.Done()
+			r.dispatchFinished(dispatchTasks(r.ctx, tasks, offsets, taskPipe), len(tasks))
+		}(current)
+	}
+
+	finishRound := func() {
+		if !current.overrun() {
+			overruns = 0
+
+			if alerted {
+				alerted = false
+				notifier.Success(roundsNotificationSite, fmt.Sprintf("Раунды проверки снова укладываются в интервал %s", cfg.ScrapeInterval))
+			}
+		}
+
+		current, roundDone = nil, nil
+
+		if pending {
+			pending = false
+			startRound()
+		}
+	}
+
+	// the sites with their own schedule don't wait for the first round
+	if cfg.HasSiteSchedules() {
+		loadTasks()
+	}
+
 	for {
 		select {
 		case <-ticker:
-			slog.Debug("starting domain check, fetching domain list", "component", "scheduler")
+			// the round may be finished at the same moment as the tick
+			select {
+			case <-roundDone:
+				finishRound()
+			default:
+			}
 
-			domains, err := getDomains()
-			if err != nil {
-				slog.Error("failed to get domain list from ISPManager", "err", err, "component", "scheduler")
+			if current == nil {
+				startRound()
 				continue
 			}
 
-			for _, domainInfo := range domains {
-				logger := slog.With("component", "scheduler", "name", domainInfo.Name, "owner", domainInfo.Owner)
+			overruns++
+			current.markOverran()
+			roundMetrics.Add("overruns", 1)
 
-				for _, site := range domainInfo.Sites {
-					logger.Debug("task sent for processing", "site", site)
+			slog.Warn("round is not finished by the next tick", "component", "scheduler", "round", current.ID,
+				"duration", time.Since(current.Start), "overruns", overruns, "policy", cfg.Schedule.Overlap)
 
-					taskPipe <- &Task{
-						DomainId:   domainInfo.Id,
-						DomainName: domainInfo.Name,
-						Owner:      domainInfo.Owner,
-						Site:       site,
-						Connection: struct {
-							Addr string
-							Port string
-						}{
-							Port: domainInfo.Port,
-							Addr: domainInfo.IPAddr,
-						},
-					}
-				}
+			if cfg.Schedule.OverrunAlert > 0 && overruns >= cfg.Schedule.OverrunAlert {
+				alerted = true
+				notifier.Slow(roundsNotificationSite, overrunMessage(current, cfg.ScrapeInterval, overruns))
+			}
+
+			switch cfg.Schedule.Overlap {
+			case config.OverlapQueue:
+				pending = true
+			case config.OverlapCancel:
+				current.cancel()
+				startRound()
+			}
+		case <-roundDone:
+			finishRound()
+		case now := <-recheck:
+			tasks := rechecks.due(now)
+			if len(tasks) == 0 {
+				continue
 			}
+
+			slog.Debug("rechecking failing sites", "component", "scheduler", "tasks", len(tasks))
+			dispatchNow(tasks)
+		case now := <-siteTimer.C:
+			tasks := sites.due(now)
+			audits.mark(tasks, now)
+			resetSiteTimer()
+
+			if len(tasks) == 0 {
+				continue
+			}
+
+			slog.Debug("checking sites by their schedule", "component", "scheduler", "tasks", len(tasks))
+			dispatchNow(tasks)
 		case <-ctx.Done():
 			return
 		}
 	}
 }
+
+func buildTasks(cfg *config.Config, domains []*isp.WebDomain, errorSignatures []*regexp.Regexp) []*Task {
+	var tasks []*Task
+
+	serverSites := groupServerSites(domains)
+
+	for _, domainInfo := range domains {
+		bypassProbes := buildBypassProbes(cfg, domainInfo)
+
+		for _, site := range domainInfo.Sites {
+			var credentials *config.Credentials
+			if item, ok := cfg.Credentials[site]; ok {
+				credentials = &item
+			}
+
+			task := &Task{
+				DomainId:   domainInfo.Id,
+				DomainName: domainInfo.Name,
+				Owner:      domainInfo.Owner,
+				Site:       site,
+				PHPVersion: domainInfo.PHPVersion,
+				PHPHandler: domainInfo.Handler,
+				Connection: struct {
+					Addr string
+					Port string
+				}{
+					Port: domainInfo.Port,
+					Addr: domainInfo.IPAddr,
+				},
+				Probes:          cfg.SiteProbes(site),
+				MaxBodySize:     cfg.MaxBodySize,
+				Latency:         cfg.SiteLatency(site),
+				ErrorSignatures: errorSignatures,
+				AuthRealm:       cfg.AuthRealm,
+				Credentials:     credentials,
+				BypassProbes:    bypassProbes,
+				Redirects:       cfg.SiteRedirects(site),
+				Timeouts:        cfg.SiteTimeouts(site),
+				Failure:         cfg.SiteFailurePolicy(site),
+				DNSResolver:     cfg.DNSResolver,
+				PublicPath:      cfg.PublicPath.Enabled,
+				ServerSites:     serverSites[domainInfo.IPAddr],
+			}
+			task.Headers = expandHeaders(cfg.SiteHeaders(site), task)
+
+			tasks = append(tasks, task)
+		}
+	}
+
+	return tasks
+}
+
+// dispatchTasks sends every task at its offset from the round start and returns the number of sent tasks,
+// it stops when the context is done.
+func dispatchTasks(ctx context.Context, tasks []*Task, offsets []time.Duration, taskPipe chan<- *Task) int {
+	start := time.Now()
+
+	for i, task := range tasks {
+		if delay := time.Until(start.Add(offsets[i])); delay > 0 {
+			timer := time.NewTimer(delay)
+
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return i
+			}
+		}
+
+		slog.Debug("task sent for processing", "component", "scheduler", "name", task.DomainName, "owner", task.Owner, "site", task.Site)
+
+		select {
+		case taskPipe <- task:
+		case <-ctx.Done():
+			return i
+		}
+	}
+
+	return len(tasks)
+}
+
+// scheduleOffsets sorts the tasks by their send time and returns the offsets from the round start. In burst mode
+// all offsets are zero. In hash mode the offset is derived from the site name, so the site is checked at the same
+// moment of every round. In even mode the tasks are spaced evenly in the order of their hash offsets.
+func scheduleOffsets(schedule config.Schedule, tasks []*Task) []time.Duration {
+	offsets := make([]time.Duration, len(tasks))
+
+	if schedule.Mode != config.ScheduleHash && schedule.Mode != config.ScheduleEven || schedule.Window <= 0 {
+		return offsets
+	}
+
+	for i, task := range tasks {
+		offsets[i] = siteOffset(task.Site, schedule.Window)
+	}
+
+	order := make([]int, len(tasks))
+	for i := range order {
+		order[i] = i
+	}
+
+	slices.SortStableFunc(order, func(a, b int) int {
+		return cmp.Compare(offsets[a], offsets[b])
+	})
+
+	sortedTasks := make([]*Task, len(tasks))
+	sortedOffsets := make([]time.Duration, len(tasks))
+
+	for i, index := range order {
+		sortedTasks[i] = tasks[index]
+		sortedOffsets[i] = offsets[index]
+
+		if schedule.Mode == config.ScheduleEven {
+			sortedOffsets[i] = schedule.Window * time.Duration(i) / time.Duration(len(tasks))
+		}
+	}
+
+	copy(tasks, sortedTasks)
+
+	return sortedOffsets
+}
+
+func siteOffset(site string, window time.Duration) time.Duration {
+	hash := fnv.New64a()
+	hash.Write([]byte(strings.ToLower(site)))
+
+	return time.Duration(hash.Sum64() % uint64(window))
+}
+
+// groupServerSites collects the sites of every server address.
+func groupServerSites(domains []*isp.WebDomain) map[string]map[string]bool {
+	result := make(map[string]map[string]bool)
+
+	for _, domainInfo := range domains {
+		if result[domainInfo.IPAddr] == nil {
+			result[domainInfo.IPAddr] = make(map[string]bool)
+		}
+
+		for _, site := range domainInfo.Sites {
+			result[domainInfo.IPAddr][strings.ToLower(site)] = true
+		}
+	}
+
+	return result
+}
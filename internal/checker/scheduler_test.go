@@ -8,8 +8,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/kias-hack/isp-site-checker/internal/config"
 	"github.com/kias-hack/isp-site-checker/internal/isp"
+	"github.com/kias-hack/isp-site-checker/internal/notify"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
 )
 
 const (
@@ -26,9 +29,9 @@ func TestLifecycle(t *testing.T) {
 	ctx, cancel := context.WithCancel(t.Context())
 
 	wg.Add(1)
-	go scheduler(ctx, wg, make(<-chan struct{}), make(chan<- *Task), func() ([]*isp.WebDomain, error) {
+	go scheduler(ctx, wg, &config.Config{}, make(<-chan struct{}), make(chan<- *Task), func() ([]*isp.WebDomain, error) {
 		return nil, nil
-	})
+	}, notify.NewMockNotifier(gomock.NewController(t)), nil, nil)
 
 	runtime.Gosched()
 
@@ -59,11 +62,11 @@ func TestGetWebdomainsFailure(t *testing.T) {
 		close(taskPipe)
 	}()
 
-	go scheduler(ctx, wg, ticker, taskPipe, func() ([]*isp.WebDomain, error) {
+	go scheduler(ctx, wg, &config.Config{}, ticker, taskPipe, func() ([]*isp.WebDomain, error) {
 		return []*isp.WebDomain{
 			{Sites: []string{"example.com"}},
 		}, fmt.Errorf("test error")
-	})
+	}, notify.NewMockNotifier(gomock.NewController(t)), nil, nil)
 
 	ticker <- struct{}{}
 	time.Sleep(10 * time.Millisecond)
@@ -113,6 +116,9 @@ func TestSendTasks(t *testing.T) {
 						Addr: host,
 						Port: port,
 					},
+					Probes:          config.ProbesDefault,
+					ErrorSignatures: phpErrorSignaturesDefault,
+					ServerSites:     map[string]bool{domainName: true},
 				},
 			},
 		},
@@ -134,6 +140,9 @@ func TestSendTasks(t *testing.T) {
 						Addr: host,
 						Port: port,
 					},
+					Probes:          config.ProbesDefault,
+					ErrorSignatures: phpErrorSignaturesDefault,
+					ServerSites:     map[string]bool{domainName: true, "www." + domainName: true},
 				},
 				{
 					DomainId:   1,
@@ -147,6 +156,9 @@ func TestSendTasks(t *testing.T) {
 						Addr: host,
 						Port: port,
 					},
+					Probes:          config.ProbesDefault,
+					ErrorSignatures: phpErrorSignaturesDefault,
+					ServerSites:     map[string]bool{domainName: true, "www." + domainName: true},
 				},
 			},
 		},
@@ -169,6 +181,9 @@ func TestSendTasks(t *testing.T) {
 						Addr: host,
 						Port: port,
 					},
+					Probes:          config.ProbesDefault,
+					ErrorSignatures: phpErrorSignaturesDefault,
+					ServerSites:     map[string]bool{domainName: true, "www." + domainName: true, "test.test": true, "www.test.test": true},
 				},
 				{
 					DomainId:   1,
@@ -182,6 +197,9 @@ func TestSendTasks(t *testing.T) {
 						Addr: host,
 						Port: port,
 					},
+					Probes:          config.ProbesDefault,
+					ErrorSignatures: phpErrorSignaturesDefault,
+					ServerSites:     map[string]bool{domainName: true, "www." + domainName: true, "test.test": true, "www.test.test": true},
 				},
 				{
 					DomainId:   2,
@@ -195,6 +213,9 @@ func TestSendTasks(t *testing.T) {
 						Addr: host,
 						Port: port,
 					},
+					Probes:          config.ProbesDefault,
+					ErrorSignatures: phpErrorSignaturesDefault,
+					ServerSites:     map[string]bool{domainName: true, "www." + domainName: true, "test.test": true, "www.test.test": true},
 				},
 				{
 					DomainId:   2,
@@ -208,6 +229,9 @@ func TestSendTasks(t *testing.T) {
 						Addr: host,
 						Port: port,
 					},
+					Probes:          config.ProbesDefault,
+					ErrorSignatures: phpErrorSignaturesDefault,
+					ServerSites:     map[string]bool{domainName: true, "www." + domainName: true, "test.test": true, "www.test.test": true},
 				},
 			},
 		},
@@ -218,9 +242,9 @@ func TestSendTasks(t *testing.T) {
 			ctx, cancel := context.WithCancel(t.Context())
 			defer cancel()
 			wg.Add(1)
-			go scheduler(ctx, wg, ticker, taskPipe, func() ([]*isp.WebDomain, error) {
+			go scheduler(ctx, wg, &config.Config{}, ticker, taskPipe, func() ([]*isp.WebDomain, error) {
 				return testCase.domains, nil
-			})
+			}, notify.NewMockNotifier(gomock.NewController(t)), nil, nil)
 
 			ticker <- struct{}{}
 			timer := time.NewTimer(1 * time.Second)
@@ -230,6 +254,8 @@ func TestSendTasks(t *testing.T) {
 				case <-timer.C:
 					t.Fatal("таймаут при прогоне теста")
 				case actualTask := <-taskPipe:
+					assert.NotNil(t, actualTask.Round)
+					actualTask.Round = nil
 					assert.Equal(t, actualTask, expectedTask)
 				}
 			}
@@ -256,3 +282,150 @@ func TestSendTasks(t *testing.T) {
 		t.Fatal("timeout")
 	}
 }
+
+func TestScheduleOffsets(t *testing.T) {
+	newTasks := func() []*Task {
+		var tasks []*Task
+		for i := range 10 {
+			tasks = append(tasks, &Task{Site: fmt.Sprintf("site%d.example.com", i)})
+		}
+
+		return tasks
+	}
+
+	window := time.Minute
+
+	burstTasks := newTasks()
+	assert.Equal(t, make([]time.Duration, 10), scheduleOffsets(config.Schedule{Mode: config.ScheduleBurst, Window: window}, burstTasks))
+	assert.Equal(t, newTasks(), burstTasks)
+
+	hashTasks := newTasks()
+	hashOffsets := scheduleOffsets(config.Schedule{Mode: config.ScheduleHash, Window: window}, hashTasks)
+	for i, task := range hashTasks {
+		assert.Equal(t, siteOffset(task.Site, window), hashOffsets[i])
+		assert.Less(t, hashOffsets[i], window)
+
+		if i > 0 {
+			assert.GreaterOrEqual(t, hashOffsets[i], hashOffsets[i-1])
+		}
+	}
+
+	evenTasks := newTasks()
+	evenOffsets := scheduleOffsets(config.Schedule{Mode: config.ScheduleEven, Window: window}, evenTasks)
+	assert.Equal(t, hashTasks, evenTasks)
+	for i := range evenOffsets {
+		assert.Equal(t, time.Duration(i)*6*time.Second, evenOffsets[i])
+	}
+}
+
+func TestDispatchTasksSpread(t *testing.T) {
+	taskPipe := make(chan *Task)
+	tasks := []*Task{{Site: "a.example.com"}, {Site: "b.example.com"}, {Site: "c.example.com"}}
+	offsets := []time.Duration{0, 30 * time.Millisecond, 60 * time.Millisecond}
+
+	done := make(chan int)
+	go func() {
+		done <- dispatchTasks(t.Context(), tasks, offsets, taskPipe)
+	}()
+
+	start := time.Now()
+	for i := range tasks {
+		assert.Same(t, tasks[i], <-taskPipe)
+		assert.GreaterOrEqual(t, time.Since(start), offsets[i])
+	}
+
+	assert.Equal(t, 3, <-done)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	go func() {
+		done <- dispatchTasks(ctx, tasks, []time.Duration{time.Hour, time.Hour, time.Hour}, taskPipe)
+	}()
+
+	cancel()
+	assert.Equal(t, 0, <-done)
+}
+
+func TestSchedulerRoundOverlap(t *testing.T) {
+	testCases := []struct {
+		overlap string
+		rounds  []uint64
+	}{
+		{overlap: config.OverlapSkip, rounds: []uint64{1, 2}},
+		{overlap: config.OverlapQueue, rounds: []uint64{1, 2, 3}},
+		{overlap: config.OverlapCancel, rounds: []uint64{1, 2, 3}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.overlap, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(t.Context())
+			defer cancel()
+
+			wg := &sync.WaitGroup{}
+			ticker := make(chan struct{})
+			taskPipe := make(chan *Task)
+
+			overran, recovered := make(chan struct{}), make(chan struct{})
+
+			notifierMock := notify.NewMockNotifier(gomock.NewController(t))
+			notifierMock.EXPECT().Slow(roundsNotificationSite, gomock.Any()).Times(1).Do(func(_, _ string) {
+				close(overran)
+			})
+			notifierMock.EXPECT().Success(roundsNotificationSite, gomock.Any()).Times(1).Do(func(_, _ string) {
+				close(recovered)
+			})
+
+			cfg := &config.Config{
+				ScrapeInterval: time.Minute,
+				Schedule:       config.Schedule{Overlap: testCase.overlap, OverrunAlert: 1},
+			}
+
+			wg.Add(1)
+			go scheduler(ctx, wg, cfg, ticker, taskPipe, func() ([]*isp.WebDomain, error) {
+				return []*isp.WebDomain{{Sites: []string{site}}}, nil
+			}, notifierMock, nil, nil)
+
+			ticker <- struct{}{}
+			task := <-taskPipe
+			rounds := []uint64{task.Round.ID}
+
+			// the first round is still running on the next tick
+			ticker <- struct{}{}
+			<-overran
+
+			if testCase.overlap == config.OverlapCancel {
+				next := <-taskPipe
+				rounds = append(rounds, next.Round.ID)
+
+				assert.Error(t, task.Round.ctx.Err())
+				task.Round.taskDone(false, true)
+				task = next
+			}
+
+			task.Round.taskDone(false, false)
+			<-task.Round.done
+
+			if testCase.overlap == config.OverlapQueue {
+				task = <-taskPipe
+				rounds = append(rounds, task.Round.ID)
+				task.Round.taskDone(true, false)
+				<-task.Round.done
+			}
+
+			ticker <- struct{}{}
+			task = <-taskPipe
+			rounds = append(rounds, task.Round.ID)
+			task.Round.taskDone(false, false)
+
+			select {
+			case <-recovered:
+			case <-time.After(time.Second):
+				t.Fatal("no recovery notification")
+			}
+
+			assert.Equal(t, testCase.rounds, rounds)
+
+			cancel()
+			wg.Wait()
+		})
+	}
+}
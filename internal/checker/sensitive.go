@@ -0,0 +1,119 @@
+package checker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+)
+
+const sensitiveBodyLimit = 64 * 1024
+
+type SensitiveResult struct {
+	URL        string
+	StatusCode int
+	Matched    bool
+	Err        error
+}
+
+// Found reports that the file is served and its content matches the signature.
+func (r SensitiveResult) Found() bool {
+	return r.Err == nil && r.Matched
+}
+
+// auditSensitiveFiles requests the sensitive files of an open site. A closed site is audited without requests,
+// its files are not served to visitors. The audit stops at the first request error.
+func auditSensitiveFiles(ctx context.Context, clients *clientSet, task *Task) error {
+	task.Result.SensitiveAudited = true
+
+	if task.Result.Closed() {
+		return nil
+	}
+
+	client := clients.get(task.Connection.Port)
+	replacer := strings.NewReplacer("{site}", task.Site, "{domain}", task.DomainName)
+	seen := make(map[string]bool)
+
+	for _, file := range task.SensitiveFiles {
+		result := SensitiveResult{
+			URL: fmt.Sprintf("%s://%s%s", schemeForPort(task.Connection.Port), task.Site, replacer.Replace(file.Path)),
+		}
+
+		if seen[result.URL] {
+			continue
+		}
+
+		seen[result.URL] = true
+
+		err := sendSensitiveRequest(ctx, client, file, task, &result)
+		if err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		task.Result.Sensitive = append(task.Result.Sensitive, result)
+
+		// the audit of a site which doesn't answer is not finished, there is no point to wait for every file
+		if err != nil {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func sendSensitiveRequest(ctx context.Context, client *http.Client, file config.SensitiveFile, task *Task, result *SensitiveResult) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, result.URL, nil)
+	if err != nil {
+		result.Err = err
+		return err
+	}
+
+	setHeaders(req, task.Headers)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		result.Err = err
+		return err
+	}
+	defer resp.Body.Close()
+
+	result.StatusCode = resp.StatusCode
+
+	// soft 404 pages answer 200 too, only the content proves the file is served
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil
+	}
+
+	body, _, err := readBody(resp.Body, sensitiveBodyLimit)
+	if err != nil {
+		result.Err = err
+		return err
+	}
+
+	result.Matched = file.Signature.Match(body)
+
+	return nil
+}
+
+// sensitiveFound returns the found files of the audited task, false when the audit was not done or failed.
+func sensitiveFound(task *Task) ([]string, bool) {
+	if !task.Result.SensitiveAudited {
+		return nil, false
+	}
+
+	var found []string
+
+	for _, result := range task.Result.Sensitive {
+		if result.Err != nil {
+			return nil, false
+		}
+
+		if result.Found() {
+			found = append(found, fmt.Sprintf("%s - %d", result.URL, result.StatusCode))
+		}
+	}
+
+	return found, true
+}
@@ -0,0 +1,170 @@
+package checker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+	"github.com/kias-hack/isp-site-checker/internal/notify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// sensitiveFiles compiles the bundled signatures as LoadConfig does.
+func sensitiveFiles() []config.SensitiveFile {
+	files := make([]config.SensitiveFile, len(config.SensitiveFilesDefault))
+
+	for i, file := range config.SensitiveFilesDefault {
+		file.Signature = regexp.MustCompile(file.Content)
+		files[i] = file
+	}
+
+	return files
+}
+
+func TestAuditSensitiveFiles(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, site, r.Host)
+		assert.Equal(t, "checker", r.Header.Get("User-Agent"))
+
+		switch r.URL.Path {
+		case "/.git/HEAD":
+			w.Write([]byte("ref: refs/heads/master\n"))
+		case "/example.com.zip":
+			w.Write([]byte("PK\x03\x04\x14\x00\x00\x00"))
+		case "/.env", "/backup.sql":
+			// soft 404 page of a CMS
+			w.Write([]byte("<html><body>Page not found</body></html>"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+
+	task := &Task{
+		Site:           site,
+		DomainName:     site,
+		Headers:        map[string]string{"User-Agent": "checker"},
+		SensitiveFiles: sensitiveFiles(),
+	}
+	task.Connection.Addr = serverURL.Hostname()
+	task.Connection.Port = serverURL.Port()
+
+	clients := newClientSet(newTransportPool(config.Pool{}, nil, nil), task.Connection.Addr, config.Timeouts{})
+
+	require.NoError(t, auditSensitiveFiles(t.Context(), clients, task))
+	assert.True(t, task.Result.SensitiveAudited)
+	assert.Len(t, task.Result.Sensitive, len(task.SensitiveFiles)-1, "the same url is requested once")
+
+	var found []string
+
+	for _, result := range task.Result.Sensitive {
+		if result.Found() {
+			found = append(found, result.URL)
+		}
+	}
+
+	assert.Equal(t, []string{"http://example.com/.git/HEAD", "http://example.com/example.com.zip"}, found)
+
+	closed := &Task{Site: site, SensitiveFiles: task.SensitiveFiles}
+	closed.Result.Probes = []ProbeResult{{
+		Probe:      config.ProbesDefault[0],
+		StatusCode: http.StatusUnauthorized,
+		Header:     http.Header{"Www-Authenticate": []string{`Basic realm="Restricted"`}},
+	}}
+
+	require.NoError(t, auditSensitiveFiles(t.Context(), clients, closed))
+	assert.True(t, closed.Result.SensitiveAudited)
+	assert.Empty(t, closed.Result.Sensitive, "files of a closed site are not requested")
+}
+
+func TestAuditSensitiveFilesUnreachable(t *testing.T) {
+	task := &Task{Site: site, SensitiveFiles: sensitiveFiles()}
+	task.Connection.Addr = "127.0.0.1"
+	task.Connection.Port = "1"
+
+	clients := newClientSet(newTransportPool(config.Pool{}, nil, nil), task.Connection.Addr, config.Timeouts{})
+
+	require.NoError(t, auditSensitiveFiles(t.Context(), clients, task))
+	require.Len(t, task.Result.Sensitive, 1, "the audit stops at the first error")
+	assert.Error(t, task.Result.Sensitive[0].Err)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	assert.ErrorIs(t, auditSensitiveFiles(ctx, clients, &Task{Site: site, SensitiveFiles: task.SensitiveFiles}), context.Canceled)
+}
+
+func TestSensitiveFound(t *testing.T) {
+	task := &Task{}
+
+	_, ok := sensitiveFound(task)
+	assert.False(t, ok, "the audit is not done")
+
+	task.Result.SensitiveAudited = true
+	task.Result.Sensitive = []SensitiveResult{
+		{URL: "http://a.ru/.env", StatusCode: http.StatusOK, Matched: true},
+		{URL: "http://a.ru/.git/HEAD", StatusCode: http.StatusNotFound},
+	}
+
+	found, ok := sensitiveFound(task)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"http://a.ru/.env - 200"}, found)
+
+	task.Result.Sensitive = append(task.Result.Sensitive, SensitiveResult{URL: "http://a.ru/dump.sql", Err: errors.New("connection reset")})
+
+	_, ok = sensitiveFound(task)
+	assert.False(t, ok, "a failed audit keeps the previous findings")
+}
+
+func TestResultHandlerSensitiveFiles(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	resultPipe := make(chan *Task)
+	wg := &sync.WaitGroup{}
+	notifierMock := notify.NewMockNotifier(gomock.NewController(t))
+
+	key := "sensitive_files:client"
+
+	gomock.InOrder(
+		notifierMock.EXPECT().Finding(notify.FindingSensitiveFiles, key, gomock.Cond(func(message string) bool {
+			return assert.Contains(t, message, "Владелец: client\nhttp://example.com/.env - 200")
+		})).Times(1),
+		notifierMock.EXPECT().Success(key, gomock.Any()).Times(1),
+	)
+	notifierMock.EXPECT().Success(site, gomock.Any()).Times(2)
+
+	wg.Add(1)
+	go resultHandler(ctx, wg, resultPipe, notifierMock, nil, nil)
+
+	probes := []ProbeResult{{Probe: config.Probe{Path: "/", Expect: config.ExpectOpen}, StatusCode: http.StatusOK}}
+
+	resultPipe <- &Task{
+		Site:  site,
+		Owner: "client",
+		Result: Result{
+			Probes:           probes,
+			Sensitive:        []SensitiveResult{{URL: "http://example.com/.env", StatusCode: http.StatusOK, Matched: true}},
+			SensitiveAudited: true,
+		},
+	}
+
+	resultPipe <- &Task{
+		Site:   site,
+		Owner:  "client",
+		Result: Result{Probes: probes, SensitiveAudited: true},
+	}
+
+	cancel()
+	wg.Wait()
+}
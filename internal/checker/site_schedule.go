@@ -0,0 +1,154 @@
+package checker
+
+import (
+	"container/heap"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+)
+
+// scheduledSite is a site checked by its own schedule instead of the rounds.
+type scheduledSite struct {
+	task     *Task
+	schedule config.CheckSchedule
+	next     time.Time
+	index    int
+	running  atomic.Bool
+}
+
+// finished marks the check of the site handled, a nil site is ignored.
+func (s *scheduledSite) finished() {
+	if s == nil {
+		return
+	}
+
+	s.running.Store(false)
+}
+
+// siteHeap orders the scheduled sites by their next check time.
+type siteHeap []*scheduledSite
+
+func (h siteHeap) Len() int           { return len(h) }
+func (h siteHeap) Less(i, j int) bool { return h[i].next.Before(h[j].next) }
+
+func (h siteHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *siteHeap) Push(x any) {
+	site := x.(*scheduledSite)
+	site.index = len(*h)
+	*h = append(*h, site)
+}
+
+func (h *siteHeap) Pop() any {
+	old := *h
+	site := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+
+	return site
+}
+
+// siteQueue is the priority queue of the sites with their own schedule, it's used by the scheduler goroutine only.
+type siteQueue struct {
+	heap  siteHeap
+	sites map[string]*scheduledSite
+}
+
+func newSiteQueue() *siteQueue {
+	return &siteQueue{sites: make(map[string]*scheduledSite)}
+}
+
+// refresh updates the scheduled sites from the tasks of the current domain list and returns the tasks
+// left for the rounds. New sites with an interval are checked at once, with a cron expression at its next match.
+func (q *siteQueue) refresh(cfg *config.Config, tasks []*Task, now time.Time) []*Task {
+	var roundTasks []*Task
+
+	current := make(map[string]bool)
+
+	for _, task := range tasks {
+		schedule := cfg.SiteSchedule(task.Site)
+		if schedule.IsZero() {
+			roundTasks = append(roundTasks, task)
+			continue
+		}
+
+		current[task.Site] = true
+
+		if site, ok := q.sites[task.Site]; ok {
+			site.task = task
+			continue
+		}
+
+		next := now
+		if schedule.Cron != nil {
+			next = schedule.Next(now)
+		}
+
+		site := &scheduledSite{task: task, schedule: schedule, next: next}
+		q.sites[task.Site] = site
+		heap.Push(&q.heap, site)
+	}
+
+	for name, site := range q.sites {
+		if !current[name] {
+			heap.Remove(&q.heap, site.index)
+			delete(q.sites, name)
+		}
+	}
+
+	return roundTasks
+}
+
+// next returns the time of the earliest scheduled check.
+func (q *siteQueue) next() (time.Time, bool) {
+	if len(q.heap) == 0 {
+		return time.Time{}, false
+	}
+
+	return q.heap[0].next, true
+}
+
+// due returns the tasks of the sites due at now and moves the sites to their next check time.
+// A site is skipped while its previous check is not handled.
+func (q *siteQueue) due(now time.Time) []*Task {
+	var tasks []*Task
+
+	for len(q.heap) > 0 && !q.heap[0].next.After(now) {
+		site := q.heap[0]
+
+		if site.running.Load() {
+			slog.Warn("previous check of the site is not finished, check skipped", "component", "scheduler", "site", site.task.Site)
+		} else {
+			site.running.Store(true)
+			tasks = append(tasks, scheduledTask(site))
+		}
+
+		site.next = site.schedule.Next(now)
+		if site.next.IsZero() {
+			slog.Warn("site schedule has no next check", "component", "scheduler", "site", site.task.Site)
+			heap.Pop(&q.heap)
+			delete(q.sites, site.task.Site)
+			continue
+		}
+
+		heap.Fix(&q.heap, 0)
+	}
+
+	return tasks
+}
+
+// scheduledTask copies the task of the site without its result, the workers fill the result of the copy.
+func scheduledTask(site *scheduledSite) *Task {
+	task := *site.task
+	task.Round = nil
+	task.Scheduled = site
+	task.Result = Result{}
+
+	return &task
+}
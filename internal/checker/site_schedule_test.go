@@ -0,0 +1,110 @@
+package checker
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+	"github.com/kias-hack/isp-site-checker/internal/cron"
+	"github.com/kias-hack/isp-site-checker/internal/isp"
+	"github.com/kias-hack/isp-site-checker/internal/notify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestSiteQueue(t *testing.T) {
+	hourly, err := cron.Parse("0 * * * *")
+	require.NoError(t, err)
+
+	cfg := &config.Config{Sites: []config.SiteConfig{
+		{Name: "fast.ru", Interval: 30 * time.Second},
+		{Name: "archive.ru", Cron: "0 * * * *", CronSchedule: hourly},
+	}}
+
+	now := time.Date(2025, time.January, 15, 10, 7, 0, 0, time.UTC)
+	q := newSiteQueue()
+
+	tasks := q.refresh(cfg, []*Task{{Site: "fast.ru"}, {Site: "archive.ru"}, {Site: "round.ru"}}, now)
+	require.Len(t, tasks, 1)
+	assert.Equal(t, "round.ru", tasks[0].Site)
+
+	next, ok := q.next()
+	require.True(t, ok)
+	assert.Equal(t, now, next, "a site with an interval is checked at once")
+
+	tasks = q.due(now)
+	require.Len(t, tasks, 1)
+	assert.Equal(t, "fast.ru", tasks[0].Site)
+	assert.Equal(t, q.sites["fast.ru"], tasks[0].Scheduled)
+
+	next, _ = q.next()
+	assert.Equal(t, now.Add(30*time.Second), next)
+
+	// the check is not handled yet, the site is skipped
+	assert.Empty(t, q.due(now.Add(30*time.Second)))
+
+	tasks[0].Scheduled.finished()
+
+	tasks = q.due(now.Add(time.Hour))
+	require.Len(t, tasks, 2)
+	assert.Equal(t, "fast.ru", tasks[0].Site)
+	assert.Equal(t, "archive.ru", tasks[1].Site)
+	assert.Equal(t, now.Add(time.Hour+30*time.Second), q.sites["fast.ru"].next)
+	assert.Equal(t, time.Date(2025, time.January, 15, 12, 0, 0, 0, time.UTC), q.sites["archive.ru"].next)
+
+	// the site is gone from the domain list
+	q.refresh(cfg, []*Task{{Site: "archive.ru"}}, now)
+	assert.Len(t, q.heap, 1)
+	assert.NotContains(t, q.sites, "fast.ru")
+
+	var round *scheduledSite
+	round.finished()
+}
+
+func TestSchedulerSiteSchedule(t *testing.T) {
+	ctx := t.Context()
+	wg := &sync.WaitGroup{}
+
+	ticker := make(chan struct{})
+	taskPipe := make(chan *Task)
+
+	cfg := &config.Config{
+		ScrapeInterval: time.Hour,
+		Sites:          []config.SiteConfig{{Name: "fast.ru", Interval: 50 * time.Millisecond}},
+	}
+
+	wg.Add(1)
+	go scheduler(ctx, wg, cfg, ticker, taskPipe, func() ([]*isp.WebDomain, error) {
+		return []*isp.WebDomain{{Sites: []string{"fast.ru", "slow.ru"}}}, nil
+	}, notify.NewMockNotifier(gomock.NewController(t)), nil, nil)
+
+	// the site is checked without waiting for the first round
+	for range 3 {
+		select {
+		case task := <-taskPipe:
+			assert.Equal(t, "fast.ru", task.Site)
+			assert.Nil(t, task.Round)
+
+			task.Scheduled.finished()
+		case <-time.After(time.Second):
+			t.Fatal("site is not checked by its schedule")
+		}
+	}
+
+	ticker <- struct{}{}
+
+	for {
+		task := <-taskPipe
+		if task.Site == "fast.ru" {
+			task.Scheduled.finished()
+			continue
+		}
+
+		assert.Equal(t, "slow.ru", task.Site)
+		assert.NotNil(t, task.Round)
+
+		break
+	}
+}
@@ -0,0 +1,123 @@
+package checker
+
+import (
+	"crypto/tls"
+	"expvar"
+	"fmt"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+)
+
+// timingMetrics keeps the timings of the last check of every site
+var timingMetrics = expvar.NewMap("timings")
+
+type Timings struct {
+	Connect time.Duration
+	TLS     time.Duration
+	TTFB    time.Duration
+	Total   time.Duration
+}
+
+func (t Timings) String() string {
+	return fmt.Sprintf("соединение %s, TLS %s, первый байт %s, всего %s",
+		t.Connect.Round(time.Millisecond), t.TLS.Round(time.Millisecond), t.TTFB.Round(time.Millisecond), t.Total.Round(time.Millisecond))
+}
+
+// newTimingTrace records connect, TLS handshake and time to first byte, the returned function gives the recorded timings
+// without Total, which is set by the caller. The start is moved to the moment the transport gets a connection, so
+// the wait for the server limits is not counted. A pooled transport may finish a dial started for the request later
+// than the request itself, so the timings are guarded.
+func newTimingTrace(start *time.Time) (*httptrace.ClientTrace, func() Timings) {
+	var (
+		mu                     sync.Mutex
+		timings                Timings
+		started                bool
+		connectStart, tlsStart time.Time
+	)
+
+	trace := &httptrace.ClientTrace{
+		GetConn: func(_ string) {
+			mu.Lock()
+			defer mu.Unlock()
+			// a retry of the transport on a broken idle connection keeps the first start
+			if !started {
+				*start = time.Now()
+				started = true
+			}
+		},
+		ConnectStart: func(_, _ string) {
+			mu.Lock()
+			defer mu.Unlock()
+			connectStart = time.Now()
+		},
+		ConnectDone: func(_, _ string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				timings.Connect = time.Since(connectStart)
+			}
+		},
+		TLSHandshakeStart: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				timings.TLS = time.Since(tlsStart)
+			}
+		},
+		GotFirstResponseByte: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			timings.TTFB = time.Since(*start)
+		},
+	}
+
+	return trace, func() Timings {
+		mu.Lock()
+		defer mu.Unlock()
+		return timings
+	}
+}
+
+// recordTimings exports the timings of the slowest probe of the task.
+func recordTimings(task *Task) {
+	var slowest Timings
+
+	for i := range task.Result.Probes {
+		if timings := task.Result.Probes[i].Timings; timings.Total > slowest.Total {
+			slowest = timings
+		}
+	}
+
+	if slowest.Total == 0 {
+		return
+	}
+
+	metrics := new(expvar.Map)
+	metrics.Set("connect_seconds", expvarFloat(slowest.Connect.Seconds()))
+	metrics.Set("tls_seconds", expvarFloat(slowest.TLS.Seconds()))
+	metrics.Set("ttfb_seconds", expvarFloat(slowest.TTFB.Seconds()))
+	metrics.Set("total_seconds", expvarFloat(slowest.Total.Seconds()))
+
+	timingMetrics.Set(task.Site, metrics)
+}
+
+// slowReason returns the description of exceeded thresholds, or an empty string.
+func slowReason(thresholds config.LatencyThresholds, timings Timings) string {
+	if thresholds.TTFB > 0 && timings.TTFB > thresholds.TTFB {
+		return fmt.Sprintf("первый байт через %s, порог %s", timings.TTFB.Round(time.Millisecond), thresholds.TTFB)
+	}
+
+	if thresholds.Total > 0 && timings.Total > thresholds.Total {
+		return fmt.Sprintf("ответ за %s, порог %s", timings.Total.Round(time.Millisecond), thresholds.Total)
+	}
+
+	return ""
+}
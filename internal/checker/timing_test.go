@@ -0,0 +1,62 @@
+package checker
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendRequestTimings(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+
+	result, err := sendRequest(t.Context(), newClientSet(newTransportPool(config.Pool{}, nil, nil), serverURL.Hostname(), config.Timeouts{}).get(serverURL.Port()), http.MethodGet, "https://example.com/", nil, 0)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Positive(t, result.Timings.Connect)
+	assert.Positive(t, result.Timings.TLS)
+	assert.GreaterOrEqual(t, result.Timings.TTFB, 20*time.Millisecond)
+	assert.GreaterOrEqual(t, result.Timings.Total, result.Timings.TTFB)
+}
+
+func TestSlowReason(t *testing.T) {
+	timings := Timings{TTFB: 2 * time.Second, Total: 3 * time.Second}
+
+	assert.Empty(t, slowReason(config.LatencyThresholds{}, timings))
+	assert.Empty(t, slowReason(config.LatencyThresholds{TTFB: 5 * time.Second, Total: 5 * time.Second}, timings))
+	assert.Equal(t, "первый байт через 2s, порог 1s", slowReason(config.LatencyThresholds{TTFB: time.Second}, timings))
+	assert.Equal(t, "ответ за 3s, порог 2.5s", slowReason(config.LatencyThresholds{Total: 2500 * time.Millisecond}, timings))
+}
+
+func TestRecordTimings(t *testing.T) {
+	task := &Task{Site: "timings.example.com"}
+	task.Result.Probes = []ProbeResult{
+		{Timings: Timings{Connect: time.Millisecond, TTFB: 10 * time.Millisecond, Total: 20 * time.Millisecond}},
+		{Timings: Timings{Connect: time.Millisecond, TLS: 5 * time.Millisecond, TTFB: 30 * time.Millisecond, Total: 40 * time.Millisecond}},
+		{},
+	}
+
+	recordTimings(task)
+
+	metrics, ok := timingMetrics.Get(task.Site).(*expvar.Map)
+	if assert.True(t, ok) {
+		assert.Equal(t, "0.005", metrics.Get("tls_seconds").String())
+		assert.Equal(t, "0.03", metrics.Get("ttfb_seconds").String())
+		assert.Equal(t, "0.04", metrics.Get("total_seconds").String())
+	}
+
+	recordTimings(&Task{Site: "down.example.com"})
+	assert.Nil(t, timingMetrics.Get("down.example.com"), "a check without timings is not exported")
+}
@@ -0,0 +1,168 @@
+package checker
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+)
+
+type transportKey struct {
+	addr     string
+	port     string
+	timeouts config.Timeouts
+	verify   bool
+}
+
+// transportPool keeps long-lived transports by server address, so connections are reused between checks.
+type transportPool struct {
+	config   config.Pool
+	limiters *limiterSet
+	outbound *outbound
+
+	mu         sync.Mutex
+	transports map[transportKey]*serverTransport
+	public     map[config.Timeouts]*http.Transport
+}
+
+func newTransportPool(cfg config.Pool, limiters *limiterSet, outbound *outbound) *transportPool {
+	return &transportPool{
+		config:     cfg,
+		limiters:   limiters,
+		outbound:   outbound,
+		transports: make(map[transportKey]*serverTransport),
+		public:     make(map[config.Timeouts]*http.Transport),
+	}
+}
+
+func (p *transportPool) transport(addr string, port string, timeouts config.Timeouts) *serverTransport {
+	return p.serverTransport(transportKey{addr: addr, port: port, timeouts: timeouts})
+}
+
+// verifiedTransport returns the transport verifying the site certificates against the site name of the request URL,
+// the requests with credentials are sent only through it.
+func (p *transportPool) verifiedTransport(addr string, port string, timeouts config.Timeouts) *serverTransport {
+	return p.serverTransport(transportKey{addr: addr, port: port, timeouts: timeouts, verify: true})
+}
+
+func (p *transportPool) serverTransport(key transportKey) *serverTransport {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	transport, ok := p.transports[key]
+	if !ok {
+		transport = newServerTransport(key.addr, key.port, key.timeouts, p.config, p.outbound.dialer(key.timeouts))
+		transport.limiter = p.limiters.get(key.addr)
+
+		if key.verify {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+
+		p.transports[key] = transport
+	}
+
+	return transport
+}
+
+// publicTransport returns the transport of the public path, it doesn't dial the pinned server address.
+func (p *transportPool) publicTransport(timeouts config.Timeouts) *http.Transport {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	transport, ok := p.public[timeouts]
+	if !ok {
+		transport = newPublicTransport(timeouts, p.outbound)
+		p.public[timeouts] = transport
+	}
+
+	return transport
+}
+
+// closeIdleConnections drops idle connections of all servers, the next checks open fresh ones.
+func (p *transportPool) closeIdleConnections() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, transport := range p.transports {
+		transport.CloseIdleConnections()
+	}
+
+	for _, transport := range p.public {
+		transport.CloseIdleConnections()
+	}
+}
+
+// serverTransport dials the pinned server address. Plain http requests of all sites share one
+// connection pool, https connections are kept per site because of SNI.
+type serverTransport struct {
+	*http.Transport
+	server  string
+	limiter *serverLimiter
+	// timeout bounds the request with its body after the limiter wait, so the time in the server queue
+	// doesn't count as the site response time
+	timeout time.Duration
+}
+
+func newServerTransport(addr string, port string, timeouts config.Timeouts, pool config.Pool, dial dialFunc) *serverTransport {
+	server := net.JoinHostPort(addr, port)
+
+	return &serverTransport{
+		server:  server,
+		timeout: timeouts.Total,
+		Transport: &http.Transport{
+			TLSClientConfig:       &tls.Config{InsecureSkipVerify: true},
+			TLSHandshakeTimeout:   timeouts.TLSHandshake,
+			ResponseHeaderTimeout: timeouts.ResponseHeader,
+			MaxIdleConns:          pool.MaxIdlePerServer,
+			MaxIdleConnsPerHost:   pool.MaxIdlePerServer,
+			MaxConnsPerHost:       pool.MaxConnsPerServer,
+			IdleConnTimeout:       pool.IdleTimeout,
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return dial(ctx, network, server)
+			},
+		},
+	}
+}
+
+func (t *serverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	release, err := t.limiter.wait(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	if t.timeout > 0 {
+		ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+		req = req.WithContext(ctx)
+
+		free := release
+		release = func() {
+			free()
+			cancel()
+		}
+	}
+
+	if req.URL.Scheme == "http" {
+		shared := req.Clone(req.Context())
+		shared.URL.Host = t.server
+
+		if shared.Host == "" {
+			shared.Host = req.URL.Host
+		}
+
+		req = shared
+	}
+
+	resp, err := t.Transport.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+
+	return resp, nil
+}
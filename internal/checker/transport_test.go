@@ -0,0 +1,110 @@
+package checker
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func newCountingServer(t testing.TB, tls bool) (*httptest.Server, *atomic.Int64) {
+	var connections atomic.Int64
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="`+r.Host+`"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections.Add(1)
+		}
+	}
+
+	if tls {
+		server.StartTLS()
+	} else {
+		server.Start()
+	}
+
+	t.Cleanup(server.Close)
+
+	return server, &connections
+}
+
+func checkSites(t testing.TB, pool *transportPool, serverURL *url.URL, sites []string) {
+	clients := newClientSet(pool, serverURL.Hostname(), config.Timeouts{})
+
+	for _, site := range sites {
+		result, err := sendRequest(t.Context(), clients.get(serverURL.Port()), http.MethodGet, serverURL.Scheme+"://"+site+"/", nil, 0)
+		if err != nil || result.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("check of %s failed: %v %d", site, err, result.StatusCode)
+		}
+	}
+}
+
+func TestTransportPoolSharesConnectionsAcrossSites(t *testing.T) {
+	server, connections := newCountingServer(t, false)
+	serverURL, _ := url.Parse(server.URL)
+
+	pool := newTransportPool(config.Pool{MaxIdlePerServer: 2}, nil, nil)
+	checkSites(t, pool, serverURL, []string{"a.example.com", "b.example.com", "c.example.com"})
+
+	assert.Equal(t, int64(1), connections.Load())
+
+	pool.closeIdleConnections()
+	checkSites(t, pool, serverURL, []string{"a.example.com"})
+
+	assert.Equal(t, int64(2), connections.Load())
+}
+
+func TestTransportPoolKeepsTLSConnectionsPerSite(t *testing.T) {
+	server, connections := newCountingServer(t, true)
+	serverURL, _ := url.Parse(server.URL)
+
+	pool := newTransportPool(config.Pool{MaxIdlePerServer: 2}, nil, nil)
+	sites := []string{"a.example.com", "b.example.com"}
+
+	checkSites(t, pool, serverURL, sites)
+	checkSites(t, pool, serverURL, sites)
+
+	assert.Equal(t, int64(2), connections.Load())
+}
+
+func BenchmarkChecks(b *testing.B) {
+	const sitesCount = 2000
+
+	sites := make([]string, sitesCount)
+	for i := range sites {
+		sites[i] = fmt.Sprintf("site%d.example.com", i)
+	}
+
+	for _, tls := range []bool{false, true} {
+		server, _ := newCountingServer(b, tls)
+		serverURL, _ := url.Parse(server.URL)
+
+		b.Run(fmt.Sprintf("tls=%v/fresh", tls), func(b *testing.B) {
+			for b.Loop() {
+				// every check gets its own transport as before pooling
+				pool := newTransportPool(config.Pool{}, nil, nil)
+				checkSites(b, pool, serverURL, sites[:1])
+				pool.closeIdleConnections()
+			}
+		})
+
+		b.Run(fmt.Sprintf("tls=%v/pooled", tls), func(b *testing.B) {
+			pool := newTransportPool(config.Pool{MaxIdlePerServer: sitesCount}, nil, nil)
+
+			i := 0
+			for b.Loop() {
+				checkSites(b, pool, serverURL, sites[i%sitesCount:i%sitesCount+1])
+				i++
+			}
+		})
+	}
+}
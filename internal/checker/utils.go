@@ -4,24 +4,67 @@ import (
 	"context"
 	"net"
 	"net/http"
-	"time"
+
+	"github.com/kias-hack/isp-site-checker/internal/config"
 )
 
-func createClient(host string, port string) *http.Client {
-	transport := &http.Transport{
-		DisableKeepAlives: true,
-		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-			return (&net.Dialer{
-				Timeout: 10 * time.Second,
-				// KeepAlive: 10 * time.Second,
-			}).DialContext(ctx, network, net.JoinHostPort(host, port))
+// newClient returns a client of the server transport, the transport applies the total timeout after the limiter wait.
+func newClient(transport *serverTransport) *http.Client {
+	return &http.Client{
+		Transport: transport,
+		// redirects are followed by the probe according to the site redirect policy
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
 		},
 	}
+}
+
+// clientSet gives clients of one server address by port, so redirects and cross-port probes reach the same server.
+type clientSet struct {
+	pool     *transportPool
+	addr     string
+	timeouts config.Timeouts
+}
+
+func newClientSet(pool *transportPool, addr string, timeouts config.Timeouts) *clientSet {
+	return &clientSet{
+		pool:     pool,
+		addr:     addr,
+		timeouts: timeouts,
+	}
+}
 
-	// TODO add configurable timeout and keepalive
+func (s *clientSet) get(port string) *http.Client {
+	return newClient(s.pool.transport(s.addr, port, s.timeouts))
+}
 
+// verified returns a client verifying the site certificates, the credentials are sent only through it, so the passwords
+// don't reach whoever answers on the server address or intercepts the connection.
+func (s *clientSet) verified(port string) *http.Client {
+	return newClient(s.pool.verifiedTransport(s.addr, port, s.timeouts))
+}
+
+// public returns a client of the public path, it follows redirects as a browser does.
+func (s *clientSet) public() *http.Client {
 	return &http.Client{
-		Transport: transport,
-		Timeout:   15 * time.Second,
+		Transport: s.pool.publicTransport(s.timeouts),
+		Timeout:   s.timeouts.Total,
 	}
 }
+
+// resolver returns the resolver of the DNS probes, it's sent from the outbound address or through the proxy.
+func (s *clientSet) resolver(addr string) *net.Resolver {
+	return s.pool.outbound.resolver(addr, s.timeouts)
+}
+
+// wait takes a slot in the server limits for a connection made outside of the HTTP transports, the returned
+// release frees it. The timeouts of the connection start after the wait.
+func (s *clientSet) wait(ctx context.Context) (func(), error) {
+	return s.pool.limiters.get(s.addr).wait(ctx)
+}
+
+// dial connects to the server port outside of the HTTP transports, the connection is counted in the server limits
+// by wait.
+func (s *clientSet) dial(ctx context.Context, port string) (net.Conn, error) {
+	return s.pool.outbound.dialer(s.timeouts)(ctx, "tcp", net.JoinHostPort(s.addr, port))
+}
@@ -6,11 +6,13 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 
+	"github.com/kias-hack/isp-site-checker/internal/config"
 	"github.com/stretchr/testify/assert"
 )
 
-func TestCreateClientDialContext(t *testing.T) {
+func TestClientSetDialContext(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
@@ -19,7 +21,7 @@ func TestCreateClientDialContext(t *testing.T) {
 	defer server.Close()
 
 	serverURL, _ := url.Parse(server.URL)
-	client := createClient(serverURL.Hostname(), serverURL.Port())
+	client := newClientSet(newTransportPool(config.Pool{}, nil, nil), serverURL.Hostname(), config.Timeouts{}).get(serverURL.Port())
 
 	resp, err := client.Get("http://yandex.ru/")
 	if err != nil {
@@ -33,3 +35,23 @@ func TestCreateClientDialContext(t *testing.T) {
 
 	assert.Equal(t, []byte("OK"), data)
 }
+
+func TestClientSetResponseHeaderTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	client := newClientSet(newTransportPool(config.Pool{}, nil, nil), serverURL.Hostname(), config.Timeouts{
+		Dial:           time.Second,
+		ResponseHeader: 50 * time.Millisecond,
+		Total:          time.Second,
+	}).get(serverURL.Port())
+
+	_, err := client.Get("http://example.com/")
+
+	assert.ErrorContains(t, err, "timeout awaiting response headers")
+}
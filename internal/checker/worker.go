@@ -5,16 +5,16 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
-	"net/http"
 	"sync"
 	"time"
 )
 
-func worker(ctx context.Context, wg *sync.WaitGroup, taskPipe <-chan *Task, resultPipe chan<- *Task, n int) {
+func worker(ctx context.Context, wg *sync.WaitGroup, pool *transportPool, taskPipe <-chan *Task, resultPipe chan<- *Task, n int) {
 	defer wg.Done()
 
 	slog.Debug("worker started", "component", fmt.Sprintf("worker[%d]", n))
 
+tasks:
 	for {
 		select {
 		case <-ctx.Done():
@@ -24,37 +24,112 @@ func worker(ctx context.Context, wg *sync.WaitGroup, taskPipe <-chan *Task, resu
 
 			logger.Debug("task received for processing", "task", task)
 
-			client := createClient(task.Connection.Addr, task.Connection.Port)
-
-			url := fmt.Sprintf("http://%s/", task.Site)
+			clients := newClientSet(pool, task.Connection.Addr, task.Timeouts)
 
 			task.Result.Timestamp = time.Now()
 
-			req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+			roundCtx := task.Round.context(ctx)
+
+			target := Target{Task: task, Clients: clients}
 
-			if err != nil {
-				logger.Debug("failed to create request", "err", err)
-				task.Result.Err = err
-			} else {
-				resp, err := client.Do(req)
+			for _, probe := range task.Probes {
+				result, err := runProbeWithRetries(roundCtx, newProbe(probe), target)
 				if errors.Is(err, context.Canceled) {
-					logger.Debug("cancelled by context")
-					return
+					if ctx.Err() != nil {
+						logger.Debug("cancelled by context")
+						return
+					}
+
+					logger.Debug("round cancelled, task dropped")
+					task.Round.taskDone(false, true)
+					continue tasks
 				}
 
-				if err != nil {
-					logger.Debug("failed to connect to server", "err", err)
-					task.Result.Err = err
+				if result.Err != nil {
+					logger.Debug("failed to connect to server", "url", result.URL, "err", result.Err)
 				} else {
-					logger.Debug("received status from server", "status", resp.StatusCode)
-					task.Result.StatusCode = resp.StatusCode
-					resp.Body.Close()
+					logger.Debug("received status from server", "url", result.URL, "status", result.StatusCode)
 				}
+
+				task.Result.Probes = append(task.Result.Probes, result)
 			}
 
-			resultPipe <- task
+			if task.Result.Closed() && task.PublicPath {
+				logger.Debug("site closed, checking public path")
+
+				if err := checkPublicPath(roundCtx, clients, task); errors.Is(err, context.Canceled) {
+					if ctx.Err() != nil {
+						logger.Debug("cancelled by context")
+						return
+					}
+
+					logger.Debug("round cancelled, task dropped")
+					task.Round.taskDone(false, true)
+					continue tasks
+				}
+			}
+
+			if task.Result.Closed() && len(task.BypassProbes) > 0 {
+				logger.Debug("site closed, auditing auth bypass")
+
+				if err := auditBypass(roundCtx, clients, task); errors.Is(err, context.Canceled) {
+					if ctx.Err() != nil {
+						logger.Debug("cancelled by context")
+						return
+					}
+
+					logger.Debug("round cancelled, task dropped")
+					task.Round.taskDone(false, true)
+					continue tasks
+				}
+			}
+
+			if len(task.SensitiveFiles) > 0 {
+				logger.Debug("auditing sensitive files")
 
-			client.CloseIdleConnections()
+				if err := auditSensitiveFiles(roundCtx, clients, task); errors.Is(err, context.Canceled) {
+					if ctx.Err() != nil {
+						logger.Debug("cancelled by context")
+						return
+					}
+
+					logger.Debug("round cancelled, task dropped")
+					task.Round.taskDone(false, true)
+					continue tasks
+				}
+			}
+
+			if len(task.ListingPaths) > 0 {
+				logger.Debug("auditing directory listings")
+
+				if err := auditListings(roundCtx, clients, task); errors.Is(err, context.Canceled) {
+					if ctx.Err() != nil {
+						logger.Debug("cancelled by context")
+						return
+					}
+
+					logger.Debug("round cancelled, task dropped")
+					task.Round.taskDone(false, true)
+					continue tasks
+				}
+			}
+
+			if task.NoIndex {
+				logger.Debug("auditing search engine protection")
+
+				if err := auditNoIndex(roundCtx, clients, task); errors.Is(err, context.Canceled) {
+					if ctx.Err() != nil {
+						logger.Debug("cancelled by context")
+						return
+					}
+
+					logger.Debug("round cancelled, task dropped")
+					task.Round.taskDone(false, true)
+					continue tasks
+				}
+			}
+
+			resultPipe <- task
 		}
 	}
 }
@@ -11,6 +11,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/kias-hack/isp-site-checker/internal/config"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/goleak"
 )
@@ -31,7 +32,7 @@ func TestWorkerLifecycle(t *testing.T) {
 	wg := &sync.WaitGroup{}
 
 	wg.Add(1)
-	go worker(ctx, wg, make(<-chan *Task), make(chan<- *Task), 0)
+	go worker(ctx, wg, newTransportPool(config.Pool{}, nil, nil), make(<-chan *Task), make(chan<- *Task), 0)
 
 	exit := make(chan struct{})
 	cancel()
@@ -62,7 +63,7 @@ func TestWorkerStopByContext(t *testing.T) {
 	}()
 
 	wg.Add(1)
-	go worker(ctx, wg, taskCh, resultPipe, 0)
+	go worker(ctx, wg, newTransportPool(config.Pool{}, nil, nil), taskCh, resultPipe, 0)
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		time.Sleep(1 * time.Second)
@@ -85,6 +86,7 @@ func TestWorkerStopByContext(t *testing.T) {
 			Addr: serverUrl.Hostname(),
 			Port: serverUrl.Port(),
 		},
+		Probes: config.ProbesDefault,
 	}
 
 	taskCh <- expectedTask
@@ -124,7 +126,7 @@ func TestWorkerErrResponse(t *testing.T) {
 	}()
 
 	wg.Add(1)
-	go worker(ctx, wg, taskCh, resultPipe, 0)
+	go worker(ctx, wg, newTransportPool(config.Pool{}, nil, nil), taskCh, resultPipe, 0)
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		hj, _ := w.(http.Hijacker)
@@ -147,12 +149,14 @@ func TestWorkerErrResponse(t *testing.T) {
 			Addr: serverUrl.Hostname(),
 			Port: serverUrl.Port(),
 		},
+		Probes: config.ProbesDefault,
 	}
 
 	taskCh <- expectedTask
 	<-wait
 	task := <-resultPipe
-	assert.Error(t, task.Result.Err)
+	assert.Len(t, task.Result.Probes, 1)
+	assert.Error(t, task.Result.Probes[0].Err)
 
 	exit := make(chan struct{})
 
@@ -184,7 +188,7 @@ func TestWorkerReturn200OK(t *testing.T) {
 	}()
 
 	wg.Add(1)
-	go worker(ctx, wg, taskCh, resultPipe, 0)
+	go worker(ctx, wg, newTransportPool(config.Pool{}, nil, nil), taskCh, resultPipe, 0)
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		close(wait)
@@ -205,13 +209,15 @@ func TestWorkerReturn200OK(t *testing.T) {
 			Addr: serverUrl.Hostname(),
 			Port: serverUrl.Port(),
 		},
+		Probes: config.ProbesDefault,
 	}
 
 	taskCh <- expectedTask
 	<-wait
 	task := <-resultPipe
-	assert.NoError(t, task.Result.Err)
-	assert.Equal(t, http.StatusOK, task.Result.StatusCode)
+	assert.Len(t, task.Result.Probes, 1)
+	assert.NoError(t, task.Result.Probes[0].Err)
+	assert.Equal(t, http.StatusOK, task.Result.Probes[0].StatusCode)
 
 	exit := make(chan struct{})
 
@@ -243,7 +249,7 @@ func TestWorkerReturnNotOKStatus(t *testing.T) {
 	}()
 
 	wg.Add(1)
-	go worker(ctx, wg, taskCh, resultPipe, 0)
+	go worker(ctx, wg, newTransportPool(config.Pool{}, nil, nil), taskCh, resultPipe, 0)
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		close(wait)
@@ -264,13 +270,15 @@ func TestWorkerReturnNotOKStatus(t *testing.T) {
 			Addr: serverUrl.Hostname(),
 			Port: serverUrl.Port(),
 		},
+		Probes: config.ProbesDefault,
 	}
 
 	taskCh <- expectedTask
 	<-wait
 	task := <-resultPipe
-	assert.NoError(t, task.Result.Err)
-	assert.Equal(t, http.StatusForbidden, task.Result.StatusCode)
+	assert.Len(t, task.Result.Probes, 1)
+	assert.NoError(t, task.Result.Probes[0].Err)
+	assert.Equal(t, http.StatusForbidden, task.Result.Probes[0].StatusCode)
 
 	exit := make(chan struct{})
 
@@ -308,7 +316,7 @@ func TestWorkerManyTasks(t *testing.T) {
 	}()
 
 	wg.Add(1)
-	go worker(ctx, wg, taskCh, resultPipe, 0)
+	go worker(ctx, wg, newTransportPool(config.Pool{}, nil, nil), taskCh, resultPipe, 0)
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusForbidden)
@@ -328,6 +336,7 @@ func TestWorkerManyTasks(t *testing.T) {
 			Addr: serverUrl.Hostname(),
 			Port: serverUrl.Port(),
 		},
+		Probes: config.ProbesDefault,
 	}
 
 	const taskCounter = 400
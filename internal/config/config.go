@@ -3,19 +3,331 @@ package config
 import (
 	"fmt"
 	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/kias-hack/isp-site-checker/internal/cron"
 	"github.com/kias-hack/isp-site-checker/internal/isp"
 	"github.com/kias-hack/isp-site-checker/internal/util"
 	"github.com/pelletier/go-toml"
 )
 
+var (
+	BypassAuditMethodsDefault = []string{"POST", "OPTIONS", "PROPFIND"}
+	BypassAuditPathsDefault   = []string{"/index.php", "/robots.txt", "/wp-login.php", "/bitrix/admin/"}
+)
+
+const (
+	ExpectClosed = "closed"
+	ExpectOpen   = "open"
+)
+
+// UserAgentDefault replaces the Go User-Agent which is blocked by some sites.
+const UserAgentDefault = "Mozilla/5.0 (compatible; isp-site-checker)"
+
+const MaxBodySizeDefault int64 = 1024 * 1024
+
+const (
+	ProbeHTTP = "http"
+	ProbeTCP  = "tcp"
+	ProbeTLS  = "tls"
+	ProbeDNS  = "dns"
+
+	ProbeTLSPortDefault = "443"
+)
+
+// Probe is one check of a site. HTTP probes send a request, TCP probes connect to Port of the site server,
+// TLS probes make a verified handshake for the site name and DNS probes resolve Host, the site name by default.
+type Probe struct {
+	Type    string            `toml:"type"`
+	Path    string            `toml:"path"`
+	Method  string            `toml:"method"`
+	Headers map[string]string `toml:"headers"`
+	Expect  string            `toml:"expect"`
+	Status  []int             `toml:"status"`
+
+	Port string `toml:"port"`
+	Host string `toml:"host"`
+	// MatchServer makes a DNS probe compare the resolved addresses with the address of the site server
+	MatchServer bool `toml:"match_server"`
+
+	BodyContains    []string `toml:"body_contains"`
+	BodyNotContains []string `toml:"body_not_contains"`
+	MinSize         int64    `toml:"min_size"`
+	MaxSize         int64    `toml:"max_size"`
+
+	BodyContainsRegexps    []*regexp.Regexp `toml:"-"`
+	BodyNotContainsRegexps []*regexp.Regexp `toml:"-"`
+}
+
+func (p *Probe) IsHTTP() bool {
+	return p.Type == "" || p.Type == ProbeHTTP
+}
+
+// HasBodyAssertions reports that the response body has to be read for the probe.
+func (p *Probe) HasBodyAssertions() bool {
+	return len(p.BodyContains) > 0 || len(p.BodyNotContains) > 0 || p.MinSize > 0 || p.MaxSize > 0
+}
+
+type LatencyThresholds struct {
+	TTFB  time.Duration `toml:"ttfb"`
+	Total time.Duration `toml:"total"`
+}
+
+const (
+	RedirectFollow = "follow"
+	RedirectNone   = "none"
+
+	RedirectRuleHTTPS          = "https"
+	RedirectRuleWWW            = "www"
+	RedirectRuleNonWWW         = "non_www"
+	RedirectRuleSameServer     = "same_server"
+	RedirectMaxDefault     int = 10
+)
+
+type RedirectPolicy struct {
+	Mode  string   `toml:"mode"`
+	Max   int      `toml:"max"`
+	Rules []string `toml:"rules"`
+}
+
+func (p *RedirectPolicy) HasRule(rule string) bool {
+	return slices.Contains(p.Rules, rule)
+}
+
+const (
+	WorkersDefault               int           = 10
+	DialTimeoutDefault           time.Duration = 10 * time.Second
+	TLSHandshakeTimeoutDefault   time.Duration = 10 * time.Second
+	ResponseHeaderTimeoutDefault time.Duration = 10 * time.Second
+	TotalTimeoutDefault          time.Duration = 15 * time.Second
+
+	MaxIdlePerServerDefault int           = 4
+	IdleTimeoutDefault      time.Duration = 90 * time.Second
+)
+
+// Timeouts of the site requests. KeepAlive is the TCP keep-alive period of the connections, a negative value disables it.
+type Timeouts struct {
+	Dial           time.Duration `toml:"dial"`
+	TLSHandshake   time.Duration `toml:"tls_handshake"`
+	ResponseHeader time.Duration `toml:"response_header"`
+	Total          time.Duration `toml:"total"`
+	KeepAlive      time.Duration `toml:"keepalive"`
+}
+
+// Pool limits the connections kept to every server address. FreshPerRound closes idle connections before each round.
+type Pool struct {
+	MaxIdlePerServer  int           `toml:"max_idle_per_server"`
+	MaxConnsPerServer int           `toml:"max_conns_per_server"`
+	IdleTimeout       time.Duration `toml:"idle_timeout"`
+	FreshPerRound     bool          `toml:"fresh_per_round"`
+}
+
+// Rate is a number of requests per second, it can be written as an integer or a float.
+type Rate float64
+
+func (r *Rate) UnmarshalTOML(value interface{}) error {
+	switch v := value.(type) {
+	case int64:
+		*r = Rate(v)
+	case float64:
+		*r = Rate(v)
+	default:
+		return fmt.Errorf("rate must be a number: %v", value)
+	}
+
+	return nil
+}
+
+// Limits of the requests to one server IP address, zero values mean no limit.
+type Limits struct {
+	MaxInFlight int  `toml:"max_in_flight"`
+	RPS         Rate `toml:"rps"`
+}
+
+type ServerLimits struct {
+	Addr        string `toml:"addr"`
+	MaxInFlight int    `toml:"max_in_flight"`
+	RPS         Rate   `toml:"rps"`
+}
+
+// Outbound is the local address the checks are sent from and an optional proxy URL: http:// for HTTP CONNECT,
+// socks5:// or socks5h:// for SOCKS5.
+type Outbound struct {
+	SourceAddr string `toml:"source_addr"`
+	Proxy      string `toml:"proxy"`
+}
+
+const (
+	ScheduleBurst = "burst"
+	ScheduleEven  = "even"
+	ScheduleHash  = "hash"
+
+	OverlapSkip   = "skip"
+	OverlapQueue  = "queue"
+	OverlapCancel = "cancel"
+
+	OverrunAlertDefault int = 3
+	// WindowShareDefault is the part of the scrape interval the checks of a round are spread over by default,
+	// the rest is left for the checks of the last sites to finish before the next round
+	WindowShareDefault = 0.5
+)
+
+// Schedule defines how the checks of a round are spread over Window and what happens when the round
+// is not finished by the next tick. OverrunAlert is the number of overrunning rounds in a row that raises an alert.
+type Schedule struct {
+	Mode         string        `toml:"mode"`
+	Window       time.Duration `toml:"window"`
+	Overlap      string        `toml:"overlap"`
+	OverrunAlert int           `toml:"overrun_alert"`
+}
+
+const RecheckMaxInFlightDefault int = 4
+
+// Recheck defines how often a failing site is checked between the rounds, zero Interval disables rechecks.
+// MaxInFlight limits the rechecks sent to the workers at the same time.
+type Recheck struct {
+	Interval    time.Duration `toml:"interval"`
+	MaxInFlight int           `toml:"max_in_flight"`
+}
+
+// AuditIntervalDefault is how often a site is audited for sensitive files, directory listings and search engine protection.
+const AuditIntervalDefault = 24 * time.Hour
+
+// SensitiveFile is a file which must not be served by a site. Path may contain {site} and {domain},
+// the file is found when the response is 2xx and its beginning matches Content.
+type SensitiveFile struct {
+	Path    string `toml:"path"`
+	Content string `toml:"content"`
+
+	Signature *regexp.Regexp `toml:"-"`
+}
+
+// SensitiveFilesDefault is the bundled list of files left on staging sites, it's replaced by the configured signatures.
+var SensitiveFilesDefault = []SensitiveFile{
+	{Path: "/.git/HEAD", Content: `^(ref: refs/|[0-9a-f]{40}\s*$)`},
+	{Path: "/.git/config", Content: `(?m)^\[core\]`},
+	{Path: "/.env", Content: `(?m)^\s*[A-Z][A-Z0-9_]*\s*=`},
+	{Path: "/composer.lock", Content: `"content-hash"\s*:`},
+	{Path: "/wp-config.php.bak", Content: `DB_PASSWORD`},
+	{Path: "/backup.sql", Content: `(?i)(-- MySQL dump|CREATE TABLE|INSERT INTO)`},
+	{Path: "/dump.sql", Content: `(?i)(-- MySQL dump|CREATE TABLE|INSERT INTO)`},
+	{Path: "/phpinfo.php", Content: `phpinfo\(\)</title>`},
+	{Path: "/info.php", Content: `phpinfo\(\)</title>`},
+	{Path: "/backup.zip", Content: `^PK\x03\x04`},
+	{Path: "/{site}.zip", Content: `^PK\x03\x04`},
+	{Path: "/{domain}.zip", Content: `^PK\x03\x04`},
+}
+
+// SensitiveFiles defines the audit of sensitive files, every site is audited once per Interval.
+type SensitiveFiles struct {
+	Enabled    bool            `toml:"enabled"`
+	Interval   time.Duration   `toml:"interval"`
+	Signatures []SensitiveFile `toml:"signatures"`
+}
+
+// NoIndex defines the audit of the search engine protection of non-production sites, every site is audited
+// once per Interval. Only the sites of the [[sites]] entries marked as staging are audited, the rest are production.
+type NoIndex struct {
+	Enabled  bool          `toml:"enabled"`
+	Interval time.Duration `toml:"interval"`
+}
+
+// DirectoryListing defines the audit of directory listings at the site root and Paths, every site is audited
+// once per Interval. RepeatInterval overrides the repeat interval of the notifications about listings.
+type DirectoryListing struct {
+	Enabled        bool          `toml:"enabled"`
+	Paths          []string      `toml:"paths"`
+	Interval       time.Duration `toml:"interval"`
+	RepeatInterval time.Duration `toml:"repeat_interval"`
+}
+
+const RetryBackoffDefault = time.Second
+
+// FailurePolicy defines when a site is declared failed: after Threshold failed checks among the last Window checks,
+// or Threshold failed checks in a row when Window is not set. Recovery is the number of passed checks in a row
+// needed to declare the site working again. Retries repeat a probe with a connection error within the round,
+// the delay starts at RetryBackoff and doubles after every attempt.
+type FailurePolicy struct {
+	Threshold    int           `toml:"threshold"`
+	Window       int           `toml:"window"`
+	Recovery     int           `toml:"recovery"`
+	Retries      int           `toml:"retries"`
+	RetryBackoff time.Duration `toml:"retry_backoff"`
+}
+
+// CheckSchedule is the own schedule of a site: every Interval or by the Cron expression.
+// The zero value means the site is checked in the rounds every scrape interval.
+type CheckSchedule struct {
+	Interval time.Duration
+	Cron     *cron.Schedule
+}
+
+func (s CheckSchedule) IsZero() bool {
+	return s.Interval == 0 && s.Cron == nil
+}
+
+// Next returns the time of the check after t, the zero time when the cron expression never matches.
+func (s CheckSchedule) Next(t time.Time) time.Time {
+	if s.Cron != nil {
+		return s.Cron.Next(t)
+	}
+
+	return t.Add(s.Interval)
+}
+
+const SiteIntervalMin = time.Second
+
+type SiteConfig struct {
+	Name      string            `toml:"name"`
+	Probes    []Probe           `toml:"probes"`
+	Latency   LatencyThresholds `toml:"latency"`
+	Redirects *RedirectPolicy   `toml:"redirects"`
+	Timeouts  Timeouts          `toml:"timeouts"`
+	Failure   *FailurePolicy    `toml:"failure"`
+	Interval  time.Duration     `toml:"interval"`
+	Cron      string            `toml:"cron"`
+	Headers   map[string]string `toml:"headers"`
+
+	// Staging marks the sites as non-production ones, they are audited for the search engine protection
+	Staging bool `toml:"staging"`
+
+	CronSchedule *cron.Schedule `toml:"-"`
+}
+
+var ProbesDefault = []Probe{
+	{Path: "/", Method: "GET", Expect: ExpectClosed},
+}
+
+type Credentials struct {
+	Site     string `toml:"site"`
+	Username string `toml:"username"`
+	Password string `toml:"password"`
+}
+
 type Config struct {
 	MgrCtlPath            string `toml:"mgrctl_path"`
 	DebugMode             bool
 	ScrapeInterval        time.Duration `toml:"scrape_interval"`
 	SiteRetentionInterval time.Duration `toml:"site_retention_interval"`
+	AuthRealm             string        `toml:"auth_realm"`
+	MaxBodySize           int64         `toml:"max_body_size"`
+	CredentialsFile       string        `toml:"credentials_file"`
+	PHPSignaturesFile     string        `toml:"php_signatures_file"`
+	Workers               int           `toml:"workers"`
+	MetricsAddr           string        `toml:"metrics_addr"`
+	DNSResolver           string        `toml:"dns_resolver"`
+
+	Credentials   map[string]Credentials `toml:"-"`
+	PHPSignatures []*regexp.Regexp       `toml:"-"`
 
 	SMTP struct {
 		Host     string `toml:"host"`
@@ -31,6 +343,37 @@ type Config struct {
 		Subject string   `toml:"subject"`
 	}
 
+	Probes    []Probe           `toml:"probes"`
+	Headers   map[string]string `toml:"headers"`
+	Sites     []SiteConfig      `toml:"sites"`
+	Latency   LatencyThresholds `toml:"latency"`
+	Redirects RedirectPolicy    `toml:"redirects"`
+	Timeouts  Timeouts          `toml:"timeouts"`
+	Pool      Pool              `toml:"pool"`
+	Limits    Limits            `toml:"limits"`
+	Servers   []ServerLimits    `toml:"servers"`
+	Outbound  Outbound          `toml:"outbound"`
+	Schedule  Schedule          `toml:"schedule"`
+	Recheck   Recheck           `toml:"recheck"`
+	Failure   FailurePolicy     `toml:"failure"`
+
+	BypassAudit struct {
+		Enabled      bool     `toml:"enabled"`
+		Methods      []string `toml:"methods"`
+		Paths        []string `toml:"paths"`
+		CrossPort    bool     `toml:"cross_port"`
+		DefaultVhost bool     `toml:"default_vhost"`
+	} `toml:"bypass_audit"`
+
+	SensitiveFiles   SensitiveFiles   `toml:"sensitive_files"`
+	DirectoryListing DirectoryListing `toml:"directory_listing"`
+	NoIndex          NoIndex          `toml:"noindex"`
+
+	// PublicPath repeats closed probes through the public DNS and the outbound or system proxy
+	PublicPath struct {
+		Enabled bool `toml:"enabled"`
+	} `toml:"public_path"`
+
 	SendInterval   time.Duration `toml:"send_interval"`
 	SendTimeout    time.Duration `toml:"send_timeout"`
 	RepeatInterval time.Duration `toml:"repeat_interval"`
@@ -58,6 +401,50 @@ func LoadConfig(configPath string, resolverFunc util.MXResolverFunc) (*Config, e
 		cfg.ScrapeInterval = time.Minute
 	}
 
+	if cfg.Schedule.Mode == "" {
+		cfg.Schedule.Mode = ScheduleBurst
+	}
+
+	if cfg.Schedule.Mode != ScheduleBurst && cfg.Schedule.Mode != ScheduleEven && cfg.Schedule.Mode != ScheduleHash {
+		return nil, fmt.Errorf("schedule mode must be %s, %s or %s: %s", ScheduleBurst, ScheduleEven, ScheduleHash, cfg.Schedule.Mode)
+	}
+
+	if cfg.Schedule.Window < 0 || cfg.Schedule.Window > cfg.ScrapeInterval {
+		return nil, fmt.Errorf("schedule window must be between 0 and scrape interval %s", cfg.ScrapeInterval)
+	}
+
+	if cfg.Schedule.Window == 0 {
+		cfg.Schedule.Window = time.Duration(float64(cfg.ScrapeInterval) * WindowShareDefault)
+	}
+
+	if cfg.Schedule.Overlap == "" {
+		cfg.Schedule.Overlap = OverlapQueue
+	}
+
+	if cfg.Schedule.Overlap != OverlapSkip && cfg.Schedule.Overlap != OverlapQueue && cfg.Schedule.Overlap != OverlapCancel {
+		return nil, fmt.Errorf("schedule overlap must be %s, %s or %s: %s", OverlapSkip, OverlapQueue, OverlapCancel, cfg.Schedule.Overlap)
+	}
+
+	if cfg.Schedule.OverrunAlert < 0 {
+		return nil, fmt.Errorf("schedule overrun_alert can't be negative")
+	}
+
+	if cfg.Schedule.OverrunAlert == 0 {
+		cfg.Schedule.OverrunAlert = OverrunAlertDefault
+	}
+
+	if cfg.Recheck.Interval < 0 || cfg.Recheck.MaxInFlight < 0 {
+		return nil, fmt.Errorf("recheck values can't be negative")
+	}
+
+	if cfg.Recheck.Interval >= cfg.ScrapeInterval {
+		return nil, fmt.Errorf("recheck interval %s must be less than scrape_interval %s", cfg.Recheck.Interval, cfg.ScrapeInterval)
+	}
+
+	if cfg.Recheck.MaxInFlight == 0 {
+		cfg.Recheck.MaxInFlight = RecheckMaxInFlightDefault
+	}
+
 	if cfg.MgrCtlPath == "" {
 		cfg.MgrCtlPath = isp.MGR_CTL_PATH_DEFAULT
 	}
@@ -99,5 +486,781 @@ func LoadConfig(configPath string, resolverFunc util.MXResolverFunc) (*Config, e
 		return nil, fmt.Errorf("interval must be greater than timeout by more than 2 seconds")
 	}
 
+	if cfg.MaxBodySize == 0 {
+		cfg.MaxBodySize = MaxBodySizeDefault
+	}
+
+	if cfg.Workers < 0 {
+		return nil, fmt.Errorf("workers count can't be negative")
+	}
+
+	if cfg.Workers == 0 {
+		cfg.Workers = WorkersDefault
+	}
+
+	if err := validateOutbound(cfg.Outbound); err != nil {
+		return nil, err
+	}
+
+	if cfg.DNSResolver != "" {
+		resolver, err := resolverAddr(cfg.DNSResolver)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.DNSResolver = resolver
+	}
+
+	if cfg.Timeouts.Dial == 0 {
+		cfg.Timeouts.Dial = DialTimeoutDefault
+	}
+
+	if cfg.Timeouts.TLSHandshake == 0 {
+		cfg.Timeouts.TLSHandshake = TLSHandshakeTimeoutDefault
+	}
+
+	if cfg.Timeouts.ResponseHeader == 0 {
+		cfg.Timeouts.ResponseHeader = ResponseHeaderTimeoutDefault
+	}
+
+	if cfg.Timeouts.Total == 0 {
+		cfg.Timeouts.Total = TotalTimeoutDefault
+	}
+
+	if err := validateTimeouts(cfg.Timeouts); err != nil {
+		return nil, err
+	}
+
+	if cfg.Pool.MaxIdlePerServer < 0 || cfg.Pool.MaxConnsPerServer < 0 || cfg.Pool.IdleTimeout < 0 {
+		return nil, fmt.Errorf("pool limits can't be negative")
+	}
+
+	if cfg.Pool.MaxConnsPerServer > 0 && cfg.Pool.MaxIdlePerServer > cfg.Pool.MaxConnsPerServer {
+		return nil, fmt.Errorf("pool max_idle_per_server can't be greater than max_conns_per_server")
+	}
+
+	if cfg.Pool.MaxIdlePerServer == 0 {
+		cfg.Pool.MaxIdlePerServer = MaxIdlePerServerDefault
+
+		if cfg.Pool.MaxConnsPerServer > 0 {
+			cfg.Pool.MaxIdlePerServer = min(MaxIdlePerServerDefault, cfg.Pool.MaxConnsPerServer)
+		}
+	}
+
+	if cfg.Pool.IdleTimeout == 0 {
+		cfg.Pool.IdleTimeout = IdleTimeoutDefault
+	}
+
+	if cfg.Limits.MaxInFlight < 0 || cfg.Limits.RPS < 0 {
+		return nil, fmt.Errorf("limits can't be negative")
+	}
+
+	for _, server := range cfg.Servers {
+		if net.ParseIP(server.Addr) == nil {
+			return nil, fmt.Errorf("invalid server address %q", server.Addr)
+		}
+
+		if server.MaxInFlight < 0 || server.RPS < 0 {
+			return nil, fmt.Errorf("server %s: limits can't be negative", server.Addr)
+		}
+	}
+
+	if len(cfg.Probes) == 0 {
+		cfg.Probes = slices.Clone(ProbesDefault)
+	}
+
+	headers := make(map[string]string, len(cfg.Headers)+1)
+	for name, value := range cfg.Headers {
+		headers[http.CanonicalHeaderKey(name)] = value
+	}
+
+	if _, ok := headers["User-Agent"]; !ok {
+		headers["User-Agent"] = UserAgentDefault
+	}
+
+	cfg.Headers = headers
+
+	if err := prepareProbes(cfg.Probes, cfg.MaxBodySize); err != nil {
+		return nil, err
+	}
+
+	if cfg.Latency.TTFB < 0 || cfg.Latency.Total < 0 {
+		return nil, fmt.Errorf("latency thresholds can't be negative")
+	}
+
+	if err := prepareRedirectPolicy(&cfg.Redirects); err != nil {
+		return nil, err
+	}
+
+	if err := prepareFailurePolicy(&cfg.Failure); err != nil {
+		return nil, err
+	}
+
+	for i := range cfg.Sites {
+		if cfg.Sites[i].Failure != nil {
+			policy := mergeFailurePolicy(cfg.Failure, *cfg.Sites[i].Failure)
+			if err := prepareFailurePolicy(&policy); err != nil {
+				return nil, fmt.Errorf("site %s: %w", cfg.Sites[i].Name, err)
+			}
+		}
+
+		if err := prepareSiteSchedule(&cfg.Sites[i]); err != nil {
+			return nil, fmt.Errorf("site %s: %w", cfg.Sites[i].Name, err)
+		}
+
+		if cfg.Sites[i].Redirects != nil {
+			if err := prepareRedirectPolicy(cfg.Sites[i].Redirects); err != nil {
+				return nil, fmt.Errorf("site %s: %w", cfg.Sites[i].Name, err)
+			}
+		}
+
+		if cfg.Sites[i].Latency.TTFB < 0 || cfg.Sites[i].Latency.Total < 0 {
+			return nil, fmt.Errorf("site %s: latency thresholds can't be negative", cfg.Sites[i].Name)
+		}
+
+		if cfg.Sites[i].Name == "" {
+			return nil, fmt.Errorf("site name is required")
+		}
+
+		if err := validateTimeouts(mergeTimeouts(cfg.Timeouts, cfg.Sites[i].Timeouts)); err != nil {
+			return nil, fmt.Errorf("site %s: %w", cfg.Sites[i].Name, err)
+		}
+
+		if _, err := path.Match(cfg.Sites[i].Name, ""); err != nil {
+			return nil, fmt.Errorf("invalid site name pattern %s: %w", cfg.Sites[i].Name, err)
+		}
+
+		if err := prepareProbes(cfg.Sites[i].Probes, cfg.MaxBodySize); err != nil {
+			return nil, fmt.Errorf("site %s: %w", cfg.Sites[i].Name, err)
+		}
+	}
+
+	if cfg.CredentialsFile != "" {
+		credentials, err := loadCredentials(cfg.CredentialsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load credentials: %w", err)
+		}
+
+		cfg.Credentials = credentials
+	}
+
+	if cfg.PHPSignaturesFile != "" {
+		signatures, err := loadSignatures(cfg.PHPSignaturesFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load php signatures: %w", err)
+		}
+
+		cfg.PHPSignatures = signatures
+	}
+
+	if cfg.BypassAudit.Enabled {
+		if len(cfg.BypassAudit.Methods) == 0 {
+			cfg.BypassAudit.Methods = BypassAuditMethodsDefault
+		}
+
+		if len(cfg.BypassAudit.Paths) == 0 {
+			cfg.BypassAudit.Paths = BypassAuditPathsDefault
+		}
+
+		for _, path := range cfg.BypassAudit.Paths {
+			if !strings.HasPrefix(path, "/") {
+				return nil, fmt.Errorf("bypass audit path must start with /: %s", path)
+			}
+		}
+	}
+
+	if cfg.SensitiveFiles.Enabled {
+		if err := prepareSensitiveFiles(&cfg.SensitiveFiles); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.DirectoryListing.Enabled {
+		if err := prepareDirectoryListing(&cfg.DirectoryListing); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.NoIndex.Interval < 0 {
+		return nil, fmt.Errorf("noindex interval can't be negative")
+	}
+
+	if cfg.NoIndex.Interval == 0 {
+		cfg.NoIndex.Interval = AuditIntervalDefault
+	}
+
+	if cfg.NoIndex.Enabled && !slices.ContainsFunc(cfg.Sites, func(site SiteConfig) bool { return site.Staging }) {
+		return nil, fmt.Errorf("noindex is enabled, but no [[sites]] entry is marked as staging")
+	}
+
 	return cfg, nil
 }
+
+// SiteConfig returns the settings of the first [[sites]] entry matching the site name, nil if there is none.
+func (c *Config) SiteConfig(site string) *SiteConfig {
+	for i := range c.Sites {
+		if ok, _ := path.Match(c.Sites[i].Name, site); ok {
+			return &c.Sites[i]
+		}
+	}
+
+	return nil
+}
+
+func (c *Config) SiteProbes(site string) []Probe {
+	if siteConfig := c.SiteConfig(site); siteConfig != nil && len(siteConfig.Probes) > 0 {
+		return siteConfig.Probes
+	}
+
+	if len(c.Probes) == 0 {
+		return ProbesDefault
+	}
+
+	return c.Probes
+}
+
+// SiteHeaders returns the default request headers with the site headers over them.
+func (c *Config) SiteHeaders(site string) map[string]string {
+	result := make(map[string]string, len(c.Headers))
+
+	for name, value := range c.Headers {
+		result[http.CanonicalHeaderKey(name)] = value
+	}
+
+	if siteConfig := c.SiteConfig(site); siteConfig != nil {
+		for name, value := range siteConfig.Headers {
+			result[http.CanonicalHeaderKey(name)] = value
+		}
+	}
+
+	if len(result) == 0 {
+		return nil
+	}
+
+	return result
+}
+
+// SiteStaging reports whether the site is marked as a non-production one, the sites without a [[sites]] entry
+// are production.
+func (c *Config) SiteStaging(site string) bool {
+	if siteConfig := c.SiteConfig(site); siteConfig != nil {
+		return siteConfig.Staging
+	}
+
+	return false
+}
+
+// SiteLatency returns the global latency thresholds overridden by non-zero site values.
+func (c *Config) SiteLatency(site string) LatencyThresholds {
+	result := c.Latency
+
+	if siteConfig := c.SiteConfig(site); siteConfig != nil {
+		if siteConfig.Latency.TTFB > 0 {
+			result.TTFB = siteConfig.Latency.TTFB
+		}
+
+		if siteConfig.Latency.Total > 0 {
+			result.Total = siteConfig.Latency.Total
+		}
+	}
+
+	return result
+}
+
+func (c *Config) SiteRedirects(site string) RedirectPolicy {
+	if siteConfig := c.SiteConfig(site); siteConfig != nil && siteConfig.Redirects != nil {
+		return *siteConfig.Redirects
+	}
+
+	return c.Redirects
+}
+
+func (c *Config) SiteTimeouts(site string) Timeouts {
+	if siteConfig := c.SiteConfig(site); siteConfig != nil {
+		return mergeTimeouts(c.Timeouts, siteConfig.Timeouts)
+	}
+
+	return c.Timeouts
+}
+
+func (c *Config) ServerLimits(addr string) Limits {
+	result := c.Limits
+
+	for _, server := range c.Servers {
+		if server.Addr != addr {
+			continue
+		}
+
+		if server.MaxInFlight != 0 {
+			result.MaxInFlight = server.MaxInFlight
+		}
+
+		if server.RPS != 0 {
+			result.RPS = server.RPS
+		}
+
+		break
+	}
+
+	return result
+}
+
+func mergeTimeouts(base Timeouts, override Timeouts) Timeouts {
+	if override.Dial != 0 {
+		base.Dial = override.Dial
+	}
+
+	if override.TLSHandshake != 0 {
+		base.TLSHandshake = override.TLSHandshake
+	}
+
+	if override.ResponseHeader != 0 {
+		base.ResponseHeader = override.ResponseHeader
+	}
+
+	if override.Total != 0 {
+		base.Total = override.Total
+	}
+
+	if override.KeepAlive != 0 {
+		base.KeepAlive = override.KeepAlive
+	}
+
+	return base
+}
+
+func validateTimeouts(timeouts Timeouts) error {
+	if timeouts.Dial < 0 || timeouts.TLSHandshake < 0 || timeouts.ResponseHeader < 0 || timeouts.Total < 0 {
+		return fmt.Errorf("timeouts can't be negative")
+	}
+
+	if timeouts.Dial > timeouts.Total {
+		return fmt.Errorf("dial timeout %s is greater than total timeout %s", timeouts.Dial, timeouts.Total)
+	}
+
+	if timeouts.TLSHandshake > timeouts.Total {
+		return fmt.Errorf("tls handshake timeout %s is greater than total timeout %s", timeouts.TLSHandshake, timeouts.Total)
+	}
+
+	if timeouts.ResponseHeader > timeouts.Total {
+		return fmt.Errorf("response header timeout %s is greater than total timeout %s", timeouts.ResponseHeader, timeouts.Total)
+	}
+
+	return nil
+}
+
+func (c *Config) SiteFailurePolicy(site string) FailurePolicy {
+	if siteConfig := c.SiteConfig(site); siteConfig != nil && siteConfig.Failure != nil {
+		return mergeFailurePolicy(c.Failure, *siteConfig.Failure)
+	}
+
+	return c.Failure
+}
+
+// mergeFailurePolicy overrides the base policy with the values set in the site policy.
+func mergeFailurePolicy(base FailurePolicy, override FailurePolicy) FailurePolicy {
+	if override.Threshold != 0 {
+		base.Threshold = override.Threshold
+	}
+
+	if override.Window != 0 {
+		base.Window = override.Window
+	}
+
+	if override.Recovery != 0 {
+		base.Recovery = override.Recovery
+	}
+
+	if override.Retries != 0 {
+		base.Retries = override.Retries
+	}
+
+	if override.RetryBackoff != 0 {
+		base.RetryBackoff = override.RetryBackoff
+	}
+
+	return base
+}
+
+// SiteSchedule returns the own schedule of the site, zero when the site is checked in the rounds.
+func (c *Config) SiteSchedule(site string) CheckSchedule {
+	if siteConfig := c.SiteConfig(site); siteConfig != nil {
+		return CheckSchedule{Interval: siteConfig.Interval, Cron: siteConfig.CronSchedule}
+	}
+
+	return CheckSchedule{}
+}
+
+// HasSiteSchedules reports that some sites are checked by their own schedule.
+func (c *Config) HasSiteSchedules() bool {
+	for i := range c.Sites {
+		if c.Sites[i].Interval > 0 || c.Sites[i].CronSchedule != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+func prepareNetProbe(probe *Probe) error {
+	if probe.Type != ProbeTCP && probe.Type != ProbeTLS && probe.Type != ProbeDNS {
+		return fmt.Errorf("probe type must be %s, %s, %s or %s: %s", ProbeHTTP, ProbeTCP, ProbeTLS, ProbeDNS, probe.Type)
+	}
+
+	if probe.Path != "" || probe.Method != "" || len(probe.Headers) > 0 || len(probe.Status) > 0 || probe.HasBodyAssertions() {
+		return fmt.Errorf("%s probe can't have http request and response settings", probe.Type)
+	}
+
+	if probe.Type == ProbeTLS && probe.Port == "" {
+		probe.Port = ProbeTLSPortDefault
+	}
+
+	if probe.Type == ProbeDNS && probe.Port != "" {
+		return fmt.Errorf("dns probe can't have a port")
+	}
+
+	if probe.Type != ProbeDNS && probe.MatchServer {
+		return fmt.Errorf("%s probe can't have match_server", probe.Type)
+	}
+
+	if probe.Type != ProbeDNS {
+		if port, err := strconv.Atoi(probe.Port); err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("invalid %s probe port %q", probe.Type, probe.Port)
+		}
+	}
+
+	if probe.Expect == "" {
+		probe.Expect = ExpectOpen
+	}
+
+	if probe.Expect != ExpectOpen && (probe.Type != ProbeTCP || probe.Expect != ExpectClosed) {
+		return fmt.Errorf("%s probe expect can't be %s", probe.Type, probe.Expect)
+	}
+
+	return nil
+}
+
+func validateOutbound(outbound Outbound) error {
+	if outbound.SourceAddr != "" && net.ParseIP(outbound.SourceAddr) == nil {
+		return fmt.Errorf("invalid outbound source_addr %q", outbound.SourceAddr)
+	}
+
+	if outbound.Proxy == "" {
+		return nil
+	}
+
+	proxyURL, err := url.Parse(outbound.Proxy)
+	if err != nil {
+		return fmt.Errorf("invalid outbound proxy: %w", err)
+	}
+
+	if proxyURL.Scheme != "http" && proxyURL.Scheme != "socks5" && proxyURL.Scheme != "socks5h" {
+		return fmt.Errorf("outbound proxy scheme must be http, socks5 or socks5h: %s", outbound.Proxy)
+	}
+
+	if proxyURL.Port() == "" {
+		return fmt.Errorf("outbound proxy port is required: %s", outbound.Proxy)
+	}
+
+	return nil
+}
+
+const DNSPortDefault = "53"
+
+// resolverAddr validates the resolver address and adds the default port.
+func resolverAddr(addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		host, port = addr, DNSPortDefault
+	}
+
+	if net.ParseIP(host) == nil {
+		return "", fmt.Errorf("invalid dns_resolver address %q", addr)
+	}
+
+	return net.JoinHostPort(host, port), nil
+}
+
+func prepareSiteSchedule(site *SiteConfig) error {
+	if site.Interval != 0 && site.Cron != "" {
+		return fmt.Errorf("interval and cron can't be set together")
+	}
+
+	if site.Interval < 0 || (site.Interval > 0 && site.Interval < SiteIntervalMin) {
+		return fmt.Errorf("interval %s must be at least %s", site.Interval, SiteIntervalMin)
+	}
+
+	if site.Cron != "" {
+		schedule, err := cron.Parse(site.Cron)
+		if err != nil {
+			return err
+		}
+
+		if schedule.Next(time.Now()).IsZero() {
+			return fmt.Errorf("cron expression %q never matches", site.Cron)
+		}
+
+		site.CronSchedule = schedule
+	}
+
+	return nil
+}
+
+func prepareFailurePolicy(policy *FailurePolicy) error {
+	if policy.Threshold < 0 || policy.Window < 0 || policy.Recovery < 0 || policy.Retries < 0 || policy.RetryBackoff < 0 {
+		return fmt.Errorf("failure policy values can't be negative")
+	}
+
+	if policy.Threshold == 0 {
+		policy.Threshold = 1
+	}
+
+	if policy.Window > 0 && policy.Threshold > policy.Window {
+		return fmt.Errorf("failure threshold %d is greater than window %d", policy.Threshold, policy.Window)
+	}
+
+	if policy.Recovery == 0 {
+		policy.Recovery = 1
+	}
+
+	if policy.RetryBackoff == 0 {
+		policy.RetryBackoff = RetryBackoffDefault
+	}
+
+	return nil
+}
+
+func prepareSensitiveFiles(audit *SensitiveFiles) error {
+	if audit.Interval < 0 {
+		return fmt.Errorf("sensitive files interval can't be negative")
+	}
+
+	if audit.Interval == 0 {
+		audit.Interval = AuditIntervalDefault
+	}
+
+	if len(audit.Signatures) == 0 {
+		audit.Signatures = slices.Clone(SensitiveFilesDefault)
+	}
+
+	for i := range audit.Signatures {
+		file := &audit.Signatures[i]
+
+		if !strings.HasPrefix(file.Path, "/") {
+			return fmt.Errorf("sensitive file path must start with /: %s", file.Path)
+		}
+
+		if file.Content == "" {
+			return fmt.Errorf("sensitive file %s: content is required", file.Path)
+		}
+
+		signature, err := regexp.Compile(file.Content)
+		if err != nil {
+			return fmt.Errorf("sensitive file %s: invalid content %q: %w", file.Path, file.Content, err)
+		}
+
+		file.Signature = signature
+	}
+
+	return nil
+}
+
+// prepareDirectoryListing puts the site root first and makes every path a directory.
+func prepareDirectoryListing(audit *DirectoryListing) error {
+	if audit.Interval < 0 || audit.RepeatInterval < 0 {
+		return fmt.Errorf("directory listing intervals can't be negative")
+	}
+
+	if audit.Interval == 0 {
+		audit.Interval = AuditIntervalDefault
+	}
+
+	paths := []string{"/"}
+
+	for _, path := range audit.Paths {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("directory listing path must start with /: %s", path)
+		}
+
+		if !strings.HasSuffix(path, "/") {
+			path += "/"
+		}
+
+		if !slices.Contains(paths, path) {
+			paths = append(paths, path)
+		}
+	}
+
+	audit.Paths = paths
+
+	return nil
+}
+
+func prepareRedirectPolicy(policy *RedirectPolicy) error {
+	if policy.Mode == "" {
+		policy.Mode = RedirectFollow
+	}
+
+	if policy.Mode != RedirectFollow && policy.Mode != RedirectNone {
+		return fmt.Errorf("redirects mode must be %s or %s: %s", RedirectFollow, RedirectNone, policy.Mode)
+	}
+
+	if policy.Max < 0 {
+		return fmt.Errorf("redirects max can't be negative")
+	}
+
+	if policy.Max == 0 {
+		policy.Max = RedirectMaxDefault
+	}
+
+	for _, rule := range policy.Rules {
+		switch rule {
+		case RedirectRuleHTTPS, RedirectRuleWWW, RedirectRuleNonWWW, RedirectRuleSameServer:
+		default:
+			return fmt.Errorf("unknown redirect rule %s", rule)
+		}
+	}
+
+	if policy.HasRule(RedirectRuleWWW) && policy.HasRule(RedirectRuleNonWWW) {
+		return fmt.Errorf("redirect rules %s and %s can't be used together", RedirectRuleWWW, RedirectRuleNonWWW)
+	}
+
+	return nil
+}
+
+func prepareProbes(probes []Probe, maxBodySize int64) error {
+	for i := range probes {
+		probe := &probes[i]
+
+		if !probe.IsHTTP() {
+			if err := prepareNetProbe(probe); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if probe.Path == "" {
+			probe.Path = "/"
+		}
+
+		if !strings.HasPrefix(probe.Path, "/") {
+			return fmt.Errorf("probe path must start with /: %s", probe.Path)
+		}
+
+		if probe.Method == "" {
+			probe.Method = "GET"
+		}
+		probe.Method = strings.ToUpper(probe.Method)
+
+		if probe.Expect == "" {
+			probe.Expect = ExpectClosed
+		}
+
+		if probe.Expect != ExpectClosed && probe.Expect != ExpectOpen {
+			return fmt.Errorf("probe expect must be %s or %s: %s", ExpectClosed, ExpectOpen, probe.Expect)
+		}
+
+		for _, status := range probe.Status {
+			if status < 100 || status > 599 {
+				return fmt.Errorf("invalid probe status %d", status)
+			}
+		}
+
+		if probe.MinSize < 0 || probe.MaxSize < 0 || (probe.MaxSize > 0 && probe.MinSize > probe.MaxSize) {
+			return fmt.Errorf("invalid probe body size limits %d..%d", probe.MinSize, probe.MaxSize)
+		}
+
+		if probe.MinSize > maxBodySize || probe.MaxSize >= maxBodySize {
+			return fmt.Errorf("probe body size limits must be less than max_body_size %d", maxBodySize)
+		}
+
+		var err error
+
+		if probe.BodyContainsRegexps, err = compileRegexps(probe.BodyContains); err != nil {
+			return err
+		}
+
+		if probe.BodyNotContainsRegexps, err = compileRegexps(probe.BodyNotContains); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func compileRegexps(patterns []string) ([]*regexp.Regexp, error) {
+	var result []*regexp.Regexp
+
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regexp %q: %w", pattern, err)
+		}
+
+		result = append(result, re)
+	}
+
+	return result, nil
+}
+
+func loadCredentials(path string) (map[string]Credentials, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.Mode().Perm()&0o077 != 0 {
+		slog.Warn("credentials file is accessible by other users", "path", path, "mode", info.Mode().Perm())
+	}
+
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file := struct {
+		Credentials []Credentials `toml:"credentials"`
+	}{}
+
+	if err := toml.Unmarshal(bytes, &file); err != nil {
+		return nil, fmt.Errorf("failed to decode credentials file: %w", err)
+	}
+
+	result := make(map[string]Credentials, len(file.Credentials))
+
+	for _, item := range file.Credentials {
+		if item.Site == "" || item.Username == "" {
+			return nil, fmt.Errorf("site and username are required for credentials")
+		}
+
+		if _, ok := result[item.Site]; ok {
+			return nil, fmt.Errorf("duplicate credentials for site %s", item.Site)
+		}
+
+		result[item.Site] = item
+	}
+
+	return result, nil
+}
+
+// loadSignatures reads regexps one per line, empty lines and lines starting with # are skipped.
+func loadSignatures(path string) ([]*regexp.Regexp, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var patterns []string
+
+	for _, line := range strings.Split(string(bytes), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		patterns = append(patterns, line)
+	}
+
+	return compileRegexps(patterns)
+}
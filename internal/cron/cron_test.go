@@ -0,0 +1,48 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNext(t *testing.T) {
+	// Wednesday
+	from := time.Date(2025, time.January, 15, 10, 7, 30, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		expr     string
+		expected time.Time
+	}{
+		{name: "every minute", expr: "* * * * *", expected: time.Date(2025, time.January, 15, 10, 8, 0, 0, time.UTC)},
+		{name: "step", expr: "*/15 * * * *", expected: time.Date(2025, time.January, 15, 10, 15, 0, 0, time.UTC)},
+		{name: "hourly", expr: "0 * * * *", expected: time.Date(2025, time.January, 15, 11, 0, 0, 0, time.UTC)},
+		{name: "business hours", expr: "*/30 9-18 * * 1-5", expected: time.Date(2025, time.January, 15, 10, 30, 0, 0, time.UTC)},
+		{name: "after business hours", expr: "0 9-18 * * 1-5", expected: time.Date(2025, time.January, 15, 11, 0, 0, 0, time.UTC)},
+		{name: "weekend", expr: "0 12 * * 6,7", expected: time.Date(2025, time.January, 18, 12, 0, 0, 0, time.UTC)},
+		{name: "next month", expr: "0 0 1 * *", expected: time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "next year", expr: "30 4 1 1 *", expected: time.Date(2026, time.January, 1, 4, 30, 0, 0, time.UTC)},
+		{name: "day of month or week", expr: "0 0 20 * 5", expected: time.Date(2025, time.January, 17, 0, 0, 0, 0, time.UTC)},
+		{name: "range with step", expr: "0 8-20/6 * * *", expected: time.Date(2025, time.January, 15, 14, 0, 0, 0, time.UTC)},
+		{name: "never", expr: "0 0 31 2 *", expected: time.Time{}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			schedule, err := Parse(testCase.expr)
+			require.NoError(t, err)
+
+			assert.Equal(t, testCase.expected, schedule.Next(from))
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		_, err := Parse(expr)
+		assert.Error(t, err, expr)
+	}
+}
@@ -1,11 +1,13 @@
 package isp
 
 type WebDomain struct {
-	Id      int
-	Name    string
-	Owner   string
-	Docroot string
-	IPAddr  string
-	Port    string
-	Sites   []string
+	Id         int
+	Name       string
+	Owner      string
+	Docroot    string
+	PHPVersion string
+	Handler    string
+	IPAddr     string
+	Port       string
+	Sites      []string
 }
@@ -70,6 +70,10 @@ func GetWebDomains(mgrctlPath string) ([]*WebDomain, error) {
 				domain.Owner = match[i]
 			case "docroot":
 				domain.Docroot = match[i]
+			case "php_version":
+				domain.PHPVersion = match[i]
+			case "handler":
+				domain.Handler = match[i]
 			case "active":
 				skip = match[i] != "on"
 			case "ipaddr":
@@ -94,6 +94,14 @@ func TestGetWebDomain(t *testing.T) {
 			if domain.IPAddr != tc.ipAddr {
 				t.Errorf("IPAddr: got %q, expected %q", domain.IPAddr, tc.ipAddr)
 			}
+
+			if domain.PHPVersion != "8.2.29 (alt)" {
+				t.Errorf("PHPVersion: got %q, expected %q", domain.PHPVersion, "8.2.29 (alt)")
+			}
+
+			if domain.Handler != "PHP Apache 8.2.29 (alt)" {
+				t.Errorf("Handler: got %q, expected %q", domain.Handler, "PHP Apache 8.2.29 (alt)")
+			}
 		})
 	}
 }
@@ -40,6 +40,18 @@ func (m *MockNotifier) EXPECT() *MockNotifierMockRecorder {
 	return m.recorder
 }
 
+// Exposed mocks base method.
+func (m *MockNotifier) Exposed(site, message string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Exposed", site, message)
+}
+
+// Exposed indicates an expected call of Exposed.
+func (mr *MockNotifierMockRecorder) Exposed(site, message any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Exposed", reflect.TypeOf((*MockNotifier)(nil).Exposed), site, message)
+}
+
 // Fail mocks base method.
 func (m *MockNotifier) Fail(site, message string) {
 	m.ctrl.T.Helper()
@@ -52,6 +64,30 @@ func (mr *MockNotifierMockRecorder) Fail(site, message any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Fail", reflect.TypeOf((*MockNotifier)(nil).Fail), site, message)
 }
 
+// Finding mocks base method.
+func (m *MockNotifier) Finding(kind FindingKind, key, message string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Finding", kind, key, message)
+}
+
+// Finding indicates an expected call of Finding.
+func (mr *MockNotifierMockRecorder) Finding(kind, key, message any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Finding", reflect.TypeOf((*MockNotifier)(nil).Finding), kind, key, message)
+}
+
+// Slow mocks base method.
+func (m *MockNotifier) Slow(site, message string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Slow", site, message)
+}
+
+// Slow indicates an expected call of Slow.
+func (mr *MockNotifierMockRecorder) Slow(site, message any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Slow", reflect.TypeOf((*MockNotifier)(nil).Slow), site, message)
+}
+
 // Stop mocks base method.
 func (m *MockNotifier) Stop(arg0 context.Context) error {
 	m.ctrl.T.Helper()
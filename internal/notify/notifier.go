@@ -17,12 +17,25 @@ type siteStatus string
 
 const (
 	Fail    siteStatus = "fail"
+	Slow    siteStatus = "slow"
+	Exposed siteStatus = "exposed"
+	Finding siteStatus = "finding"
 	Success siteStatus = "success"
 )
 
+// FindingKind is the type of a security finding, the findings of every kind are sent separately.
+type FindingKind string
+
+const (
+	FindingSensitiveFiles   FindingKind = "sensitive_files"
+	FindingDirectoryListing FindingKind = "directory_listing"
+	FindingNoIndex          FindingKind = "noindex"
+)
+
 type SiteNotification struct {
 	Site        string
 	Status      siteStatus
+	Kind        FindingKind
 	Message     string
 	NeedNotify  bool
 	LastSended  time.Time
@@ -32,6 +45,10 @@ type SiteNotification struct {
 type Notifier interface {
 	Success(site string, message string)
 	Fail(site string, message string)
+	Slow(site string, message string)
+	Exposed(site string, message string)
+	// Finding reports a security finding, the key is usually the owner of the sites. A changed message is sent again.
+	Finding(kind FindingKind, key string, message string)
 	Stop(context.Context) error
 }
 
@@ -54,6 +71,14 @@ func NewNotifier(cfg *config.Config, mailSender MailSender) Notifier {
 			Subject: cfg.EMail.Subject,
 		},
 		sitesMap: make(map[string]*SiteNotification),
+		findingRepeat: map[FindingKind]time.Duration{
+			FindingDirectoryListing: cfg.DirectoryListing.RepeatInterval,
+		},
+		auditInterval: map[FindingKind]time.Duration{
+			FindingSensitiveFiles:   cfg.SensitiveFiles.Interval,
+			FindingDirectoryListing: cfg.DirectoryListing.Interval,
+			FindingNoIndex:          cfg.NoIndex.Interval,
+		},
 	}
 
 	n.start()
@@ -66,6 +91,8 @@ type notifier struct {
 	timeout               time.Duration
 	interval              time.Duration
 	repeatInterval        time.Duration
+	findingRepeat         map[FindingKind]time.Duration
+	auditInterval         map[FindingKind]time.Duration
 	siteRetentionInterval time.Duration
 	stop                  chan struct{}
 	mailSender            MailSender
@@ -83,23 +110,40 @@ type notifier struct {
 }
 
 func (n *notifier) Success(site string, message string) {
+	n.setStatus(site, Success, message, "site closed")
+}
+
+func (n *notifier) Fail(site string, message string) {
+	n.setStatus(site, Fail, message, "site returned invalid response")
+}
+
+func (n *notifier) Slow(site string, message string) {
+	n.setStatus(site, Slow, message, "site responds slowly")
+}
+
+func (n *notifier) Exposed(site string, message string) {
+	n.setStatus(site, Exposed, message, "site is closed on the server but open through the public path")
+}
+
+func (n *notifier) Finding(kind FindingKind, key string, message string) {
 	n.mu.Lock()
 	defer n.mu.Unlock()
 
-	siteInfo := n.getSite(site)
+	info := n.getSite(key)
 
-	siteInfo.LastUpdated = time.Now()
+	info.LastUpdated = time.Now()
 
-	if siteInfo.Status != Success {
-		slog.Info("site closed", "site", site)
+	if info.Status != Finding || info.Message != message {
+		slog.Info("security finding detected", "key", key)
 
-		siteInfo.NeedNotify = true
-		siteInfo.Message = message
-		siteInfo.Status = Success
+		info.NeedNotify = true
+		info.Message = message
+		info.Status = Finding
+		info.Kind = kind
 	}
 }
 
-func (n *notifier) Fail(site string, message string) {
+func (n *notifier) setStatus(site string, status siteStatus, message string, logMessage string) {
 	n.mu.Lock()
 	defer n.mu.Unlock()
 
@@ -107,12 +151,12 @@ func (n *notifier) Fail(site string, message string) {
 
 	siteInfo.LastUpdated = time.Now()
 
-	if siteInfo.Status != Fail {
-		slog.Info("site returned invalid response", "site", site)
+	if siteInfo.Status != status {
+		slog.Info(logMessage, "site", site)
 
 		siteInfo.NeedNotify = true
 		siteInfo.Message = message
-		siteInfo.Status = Fail
+		siteInfo.Status = status
 	}
 }
 
@@ -197,7 +241,7 @@ func (n *notifier) worker() {
 
 			n.mu.Lock()
 			for site, info := range n.sitesMap {
-				if time.Since(info.LastUpdated) >= n.siteRetentionInterval {
+				if time.Since(info.LastUpdated) >= n.retentionOf(info) {
 					slog.Debug("cleaning up site record", "site", site, "period", time.Since(info.LastSended))
 					delete(n.sitesMap, site)
 					continue
@@ -263,9 +307,28 @@ func (n *notifier) canSendMail(info *SiteNotification) bool {
 
 	slog.Debug("checking repeat notification send", "info", info, "since", time.Since(info.LastSended))
 
-	if info.Status == Fail && time.Since(info.LastSended) >= n.repeatInterval {
+	if (info.Status == Fail || info.Status == Slow || info.Status == Exposed || info.Status == Finding) && time.Since(info.LastSended) >= n.repeatIntervalOf(info) {
 		return true
 	}
 
 	return false
 }
+
+// retentionOf returns how long the record is kept without updates. A finding is reported again only by the next
+// audit, so its record outlives the audit interval.
+func (n *notifier) retentionOf(info *SiteNotification) time.Duration {
+	if info.Status == Finding {
+		return n.siteRetentionInterval + n.auditInterval[info.Kind]
+	}
+
+	return n.siteRetentionInterval
+}
+
+// repeatIntervalOf returns the repeat interval of the notification, the finding kinds may override the common one.
+func (n *notifier) repeatIntervalOf(info *SiteNotification) time.Duration {
+	if interval := n.findingRepeat[info.Kind]; info.Status == Finding && interval > 0 {
+		return interval
+	}
+
+	return n.repeatInterval
+}
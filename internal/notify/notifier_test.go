@@ -271,6 +271,193 @@ func TestNotifierRepeatSend(t *testing.T) {
 	stopNotifier(t, notifier)
 }
 
+func TestNotifierSlow(t *testing.T) {
+	ctrl, sender := newMockSender(t)
+	defer ctrl.Finish()
+	sender.EXPECT().Send(gomock.Any(), gomock.Any()).Times(3)
+	notifier := &notifier{
+		wg:                    &sync.WaitGroup{},
+		timeout:               1 * time.Millisecond,
+		interval:              1 * time.Millisecond,
+		repeatInterval:        time.Hour,
+		siteRetentionInterval: time.Hour,
+		ticker:                make(chan struct{}),
+		mailSender:            sender,
+		stop:                  make(chan struct{}),
+		sitesMap:              make(map[string]*SiteNotification),
+	}
+	notifier.wg.Add(1)
+	go notifier.worker()
+
+	notifier.Slow("site", "slow")
+	notifier.ticker <- struct{}{}
+	notifier.ticker <- struct{}{}
+	assert.Equal(t, Slow, siteRecord(notifier, "site").Status)
+	assert.False(t, siteRecord(notifier, "site").NeedNotify)
+
+	notifier.Slow("site", "slow")
+	notifier.ticker <- struct{}{}
+	notifier.mu.Lock()
+	notifier.sitesMap["site"].LastSended = notifier.sitesMap["site"].LastSended.Add(-notifier.repeatInterval).Add(-time.Second * 3)
+	notifier.mu.Unlock()
+	notifier.ticker <- struct{}{}
+	notifier.ticker <- struct{}{}
+
+	notifier.Fail("site", "fail")
+	notifier.ticker <- struct{}{}
+	notifier.ticker <- struct{}{}
+	assert.Equal(t, Fail, siteRecord(notifier, "site").Status)
+
+	stopNotifier(t, notifier)
+}
+
+func TestNotifierExposed(t *testing.T) {
+	ctrl, sender := newMockSender(t)
+	defer ctrl.Finish()
+	sender.EXPECT().Send(gomock.Any(), gomock.Any()).Times(3)
+	notifier := &notifier{
+		wg:                    &sync.WaitGroup{},
+		timeout:               1 * time.Millisecond,
+		interval:              1 * time.Millisecond,
+		repeatInterval:        time.Hour,
+		siteRetentionInterval: time.Hour,
+		ticker:                make(chan struct{}),
+		mailSender:            sender,
+		stop:                  make(chan struct{}),
+		sitesMap:              make(map[string]*SiteNotification),
+	}
+	notifier.wg.Add(1)
+	go notifier.worker()
+
+	notifier.Exposed("site", "exposed")
+	notifier.ticker <- struct{}{}
+	notifier.ticker <- struct{}{}
+	assert.Equal(t, Exposed, siteRecord(notifier, "site").Status)
+	assert.False(t, siteRecord(notifier, "site").NeedNotify)
+
+	notifier.mu.Lock()
+	notifier.sitesMap["site"].LastSended = notifier.sitesMap["site"].LastSended.Add(-notifier.repeatInterval).Add(-time.Second * 3)
+	notifier.mu.Unlock()
+	notifier.ticker <- struct{}{}
+	notifier.ticker <- struct{}{}
+
+	notifier.Success("site", "closed")
+	notifier.ticker <- struct{}{}
+	notifier.ticker <- struct{}{}
+	assert.Equal(t, Success, siteRecord(notifier, "site").Status)
+
+	stopNotifier(t, notifier)
+}
+
+func TestNotifierFinding(t *testing.T) {
+	ctrl, sender := newMockSender(t)
+	defer ctrl.Finish()
+	sender.EXPECT().Send(gomock.Any(), gomock.Any()).Times(3)
+	notifier := &notifier{
+		wg:                    &sync.WaitGroup{},
+		timeout:               1 * time.Millisecond,
+		interval:              1 * time.Millisecond,
+		repeatInterval:        time.Hour,
+		siteRetentionInterval: time.Hour,
+		ticker:                make(chan struct{}),
+		mailSender:            sender,
+		stop:                  make(chan struct{}),
+		sitesMap:              make(map[string]*SiteNotification),
+	}
+	notifier.wg.Add(1)
+	go notifier.worker()
+
+	notifier.Finding(FindingSensitiveFiles, "owner", "/.env")
+	notifier.ticker <- struct{}{}
+	notifier.ticker <- struct{}{}
+	assert.Equal(t, Finding, siteRecord(notifier, "owner").Status)
+
+	// the same finding is not sent again before the repeat interval
+	notifier.Finding(FindingSensitiveFiles, "owner", "/.env")
+	notifier.ticker <- struct{}{}
+	notifier.ticker <- struct{}{}
+	assert.False(t, siteRecord(notifier, "owner").NeedNotify)
+
+	notifier.Finding(FindingSensitiveFiles, "owner", "/.env\n/.git/HEAD")
+	notifier.ticker <- struct{}{}
+	notifier.ticker <- struct{}{}
+
+	notifier.Success("owner", "resolved")
+	notifier.ticker <- struct{}{}
+	notifier.ticker <- struct{}{}
+	assert.Equal(t, Success, siteRecord(notifier, "owner").Status)
+
+	stopNotifier(t, notifier)
+}
+
+func TestNotifierFindingRepeatInterval(t *testing.T) {
+	notifier := &notifier{
+		repeatInterval: time.Hour,
+		findingRepeat:  map[FindingKind]time.Duration{FindingDirectoryListing: 72 * time.Hour},
+	}
+
+	sent := time.Now().Add(-5 * time.Hour)
+
+	assert.True(t, notifier.canSendMail(&SiteNotification{Status: Finding, Kind: FindingSensitiveFiles, LastSended: sent}))
+	assert.False(t, notifier.canSendMail(&SiteNotification{Status: Finding, Kind: FindingDirectoryListing, LastSended: sent}))
+	assert.True(t, notifier.canSendMail(&SiteNotification{Status: Finding, Kind: FindingDirectoryListing, LastSended: sent.Add(-72 * time.Hour)}))
+	assert.True(t, notifier.canSendMail(&SiteNotification{Status: Fail, Kind: FindingDirectoryListing, LastSended: sent}))
+}
+
+func TestNotifierFindingRetention(t *testing.T) {
+	ctrl, sender := newMockSender(t)
+	defer ctrl.Finish()
+	sender.EXPECT().Send(gomock.Any(), gomock.Any()).Times(1)
+	notifier := &notifier{
+		wg:                    &sync.WaitGroup{},
+		timeout:               1 * time.Millisecond,
+		interval:              1 * time.Millisecond,
+		repeatInterval:        time.Hour * 24,
+		ticker:                make(chan struct{}),
+		mailSender:            sender,
+		stop:                  make(chan struct{}),
+		sitesMap:              make(map[string]*SiteNotification),
+		siteRetentionInterval: time.Minute * 4,
+		auditInterval:         map[FindingKind]time.Duration{FindingSensitiveFiles: time.Hour * 24},
+	}
+	notifier.wg.Add(1)
+	go notifier.worker()
+
+	notifier.Finding(FindingSensitiveFiles, "owner", "/.env")
+	notifier.ticker <- struct{}{}
+	notifier.ticker <- struct{}{}
+
+	// the finding is not updated between the audits, the record is kept and the finding is not sent again
+	notifier.mu.Lock()
+	notifier.sitesMap["owner"].LastUpdated = time.Now().Add(-time.Hour * 2)
+	notifier.mu.Unlock()
+	notifier.ticker <- struct{}{}
+	notifier.ticker <- struct{}{}
+
+	notifier.Finding(FindingSensitiveFiles, "owner", "/.env")
+	notifier.ticker <- struct{}{}
+	notifier.ticker <- struct{}{}
+
+	notifier.mu.Lock()
+	info, ok := notifier.sitesMap["owner"]
+	if ok {
+		assert.False(t, info.NeedNotify)
+		info.LastUpdated = time.Now().Add(-time.Hour * 25)
+	}
+	notifier.mu.Unlock()
+	assert.True(t, ok, "finding record was removed before the next audit")
+
+	notifier.ticker <- struct{}{}
+	notifier.ticker <- struct{}{}
+
+	notifier.mu.Lock()
+	_, ok = notifier.sitesMap["owner"]
+	notifier.mu.Unlock()
+	assert.False(t, ok, "finding record is removed after the audit interval and retention")
+
+	stopNotifier(t, notifier)
+}
+
 func TestNotifierDeleteOldSites(t *testing.T) {
 	ctrl, sender := newMockSender(t)
 	defer ctrl.Finish()
@@ -336,6 +523,18 @@ func TestNotifierCheckNoDeletingCases(t *testing.T) {
 	stopNotifier(t, notifier)
 }
 
+// siteRecord copies the record of the site under the lock, the worker updates it concurrently.
+func siteRecord(n *notifier, site string) SiteNotification {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if info, ok := n.sitesMap[site]; ok {
+		return *info
+	}
+
+	return SiteNotification{}
+}
+
 func newMockSender(t *testing.T) (*gomock.Controller, *MockMailSender) {
 	ctrl := gomock.NewController(t)
 	sender := NewMockMailSender(ctrl)